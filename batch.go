@@ -0,0 +1,289 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	// ErrBatchFailed is returned by ExecBatch if at least one statement of the batch failed. The error of each
+	// statement is reported in its BatchResult.
+	//
+	// @Available since v1.4.0
+	ErrBatchFailed = errors.New("batch execution failed")
+)
+
+const (
+	// MaxBatchSize is the maximum number of statements DynamoDB executes in a single BatchExecuteStatement call.
+	//
+	// @Available since v1.4.0
+	MaxBatchSize = 25
+
+	// DefaultBatchConcurrency is the number of BatchExecuteStatement calls ExecBatch makes concurrently if not
+	// configured.
+	//
+	// @Available since v1.4.0
+	DefaultBatchConcurrency = 4
+)
+
+// Statement is a PartiQL statement with its parameters, executed by ExecBatch.
+//
+// @Available since v1.4.0
+type Statement struct {
+	// Query is the PartiQL statement, e.g. `INSERT INTO "tbl" VALUE {'id': ?, 'name': ?}`.
+	Query string
+
+	// Args are the values of the placeholders of Query.
+	Args []interface{}
+
+	// ConsistentRead, if true, makes a SELECT statement use strongly consistent read.
+	ConsistentRead bool
+}
+
+// BatchResult is the result of a statement executed by ExecBatch.
+//
+// @Available since v1.4.0
+type BatchResult struct {
+	// Item is the item returned by a SELECT statement, nil if not found or if the statement is not a SELECT.
+	Item map[string]interface{}
+
+	// Err is the error of the statement, nil if it succeeded. Errors reported by DynamoDB for the statement are of
+	// type *BatchStatementError.
+	Err error
+}
+
+// BatchStatementError is the error DynamoDB reports for a statement of a batch.
+//
+// @Available since v1.4.0
+type BatchStatementError struct {
+	// Code is the error code, e.g. "ConditionalCheckFailed" or "ValidationError", see types.BatchStatementErrorCodeEnum.
+	Code string
+
+	// Message is the error message.
+	Message string
+}
+
+// Error implements error/Error.
+func (e *BatchStatementError) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
+
+// retryableBatchErrorCodes lists the error codes of batch statements that are retried.
+var retryableBatchErrorCodes = map[types.BatchStatementErrorCodeEnum]bool{
+	types.BatchStatementErrorCodeEnumProvisionedThroughputExceeded: true,
+	types.BatchStatementErrorCodeEnumRequestLimitExceeded:          true,
+	types.BatchStatementErrorCodeEnumThrottlingError:               true,
+	types.BatchStatementErrorCodeEnumTransactionConflict:           true,
+	types.BatchStatementErrorCodeEnumInternalServerError:           true,
+}
+
+// BatchOptions are the options of ExecBatch.
+//
+// @Available since v1.4.0
+type BatchOptions struct {
+	// Concurrency is the maximum number of BatchExecuteStatement calls made concurrently. If zero,
+	// DefaultBatchConcurrency is used.
+	Concurrency int
+
+	// Retry is the retry policy of statements DynamoDB failed with a retryable error (e.g. ThrottlingError or
+	// ProvisionedThroughputExceeded). Only the failed statements of a batch are retried. Failed calls to
+	// BatchExecuteStatement are retried by the client as configured in the Config.
+	Retry RetryPolicy
+}
+
+var reBatchRead = regexp.MustCompile(`(?i)^\s*SELECT\s`)
+
+// batchChunk is a group of statements executed by a single BatchExecuteStatement call.
+type batchChunk struct {
+	indexes []int // indexes of the statements in the batch
+}
+
+// ExecBatch executes statements via BatchExecuteStatement, non-atomically: statements are grouped into batches of
+// up to MaxBatchSize statements, executed concurrently. Statements DynamoDB fails with a retryable error (e.g.
+// throttled) are retried with backoff.
+//
+// The returned slice holds the result of each statement, in the same order as statements. If at least one statement
+// failed, ErrBatchFailed is also returned. Other errors (e.g. failing to get a connection from db) are returned
+// without results.
+//
+// Since DynamoDB requires a batch to hold either only reads or only writes, consecutive SELECT statements are
+// grouped separately from other statements. A batch must not have more than one statement on the same item.
+//
+// Example:
+//
+//	statements := make([]godynamo.Statement, 0, len(items))
+//	for _, item := range items {
+//		statements = append(statements, godynamo.Statement{Query: `INSERT INTO "tbl" VALUE {'id': ?, 'name': ?}`, Args: []interface{}{item.ID, item.Name}})
+//	}
+//	results, err := godynamo.ExecBatch(ctx, db, statements, func(opts *godynamo.BatchOptions) {
+//		opts.Concurrency = 8
+//	})
+//	if errors.Is(err, godynamo.ErrBatchFailed) {
+//		for i, result := range results {
+//			if result.Err != nil {
+//				... // statements[i] failed
+//			}
+//		}
+//	}
+//
+// @Available since v1.4.0
+func ExecBatch(ctx context.Context, db *sql.DB, statements []Statement, optFns ...func(*BatchOptions)) ([]BatchResult, error) {
+	opts := BatchOptions{Concurrency: DefaultBatchConcurrency}
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultBatchConcurrency
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	var c *Conn
+	if err = conn.Raw(func(driverConn any) error {
+		var ok bool
+		if c, ok = driverConn.(*Conn); !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(statements))
+	requests := make([]types.BatchStatementRequest, len(statements))
+	var chunks []batchChunk
+	for i, statement := range statements {
+		requests[i] = types.BatchStatementRequest{Statement: aws.String(strings.TrimSpace(statement.Query))}
+		if statement.ConsistentRead {
+			requests[i].ConsistentRead = aws.Bool(true)
+		}
+		for j, arg := range statement.Args {
+			av, err := ToAttributeValue(arg)
+			if err != nil {
+				results[i].Err = fmt.Errorf("error marshalling parameter %d-th: %s", j+1, err)
+				break
+			}
+			requests[i].Parameters = append(requests[i].Parameters, av)
+		}
+		if results[i].Err != nil {
+			continue
+		}
+		last := len(chunks) - 1
+		if last < 0 || len(chunks[last].indexes) >= MaxBatchSize ||
+			reBatchRead.MatchString(statement.Query) != reBatchRead.MatchString(statements[chunks[last].indexes[0]].Query) {
+			chunks = append(chunks, batchChunk{})
+			last++
+		}
+		chunks[last].indexes = append(chunks[last].indexes, i)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, opts.Concurrency)
+	for _, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk batchChunk) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.execBatchChunk(ctx, chunk, requests, results, opts.Retry)
+		}(chunk)
+	}
+	wg.Wait()
+
+	numFailed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			numFailed++
+		}
+	}
+	if numFailed > 0 {
+		return results, fmt.Errorf("%w: %d of %d statements failed", ErrBatchFailed, numFailed, len(statements))
+	}
+	return results, nil
+}
+
+// execBatchChunk executes the statements of a chunk, retrying the ones failed with a retryable error, and stores
+// their results.
+func (c *Conn) execBatchChunk(ctx context.Context, chunk batchChunk, requests []types.BatchStatementRequest, results []BatchResult, policy RetryPolicy) {
+	pending := chunk.indexes
+	backoff := policy.backoff()
+	for attempt := 1; len(pending) > 0; attempt++ {
+		input := &dynamodb.BatchExecuteStatementInput{
+			Statements:             make([]types.BatchStatementRequest, len(pending)),
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		}
+		for i, index := range pending {
+			input.Statements[i] = requests[index]
+		}
+		reqCtx, cancel := c.requestContext(ctx)
+		output, err := c.client.BatchExecuteStatement(reqCtx, input)
+		cancel()
+		if err != nil {
+			for _, index := range pending {
+				results[index].Err = err
+			}
+			return
+		}
+		collector := capacityCollectorFromContext(ctx)
+		for i := range output.ConsumedCapacity {
+			collector.add(&output.ConsumedCapacity[i])
+		}
+
+		var retry []int
+		var retryErr error
+		for i, index := range pending {
+			if i >= len(output.Responses) {
+				results[index].Err = fmt.Errorf("no response for statement <%s>", aws.ToString(requests[index].Statement))
+				continue
+			}
+			response := output.Responses[i]
+			if response.Error != nil {
+				stmtErr := &BatchStatementError{Code: string(response.Error.Code), Message: aws.ToString(response.Error.Message)}
+				results[index].Err = stmtErr
+				if retryableBatchErrorCodes[response.Error.Code] && attempt < policy.maxAttempts() {
+					retry, retryErr = append(retry, index), stmtErr
+				}
+				continue
+			}
+			results[index].Err = nil
+			if response.Item != nil {
+				item, err := c.numberMode.unmarshal(&types.AttributeValueMemberM{Value: response.Item})
+				if err != nil {
+					results[index].Err = err
+					continue
+				}
+				results[index].Item, _ = item.(map[string]interface{})
+			}
+		}
+		if pending = retry; len(pending) > 0 {
+			delay, err := backoff.BackoffDelay(attempt, retryErr)
+			if err != nil {
+				return
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				for _, index := range pending {
+					results[index].Err = ctx.Err()
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// _batchStubServer returns a server that serves BatchExecuteStatement requests: SELECT statements return an item
+// with the first parameter as id, statements with parameter "fail" fail with ConditionalCheckFailed, and statements
+// with parameter "throttle" are throttled the first time. The number of statements of each call is recorded in sizes.
+func _batchStubServer(sizes *[]int) *stubDynamoDBServer {
+	var lock sync.Mutex
+	throttled := map[string]bool{}
+	return newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		statements, _ := req["Statements"].([]interface{})
+		lock.Lock()
+		defer lock.Unlock()
+		*sizes = append(*sizes, len(statements))
+		var responses []interface{}
+		for _, s := range statements {
+			statement := s.(map[string]interface{})
+			param := statement["Parameters"].([]interface{})[0].(map[string]interface{})["S"].(string)
+			switch {
+			case param == "fail":
+				responses = append(responses, map[string]interface{}{"Error": map[string]interface{}{"Code": "ConditionalCheckFailed", "Message": "failed"}})
+			case strings.HasPrefix(param, "throttle") && !throttled[param]:
+				throttled[param] = true
+				responses = append(responses, map[string]interface{}{"Error": map[string]interface{}{"Code": "ThrottlingError"}})
+			case strings.HasPrefix(statement["Statement"].(string), "SELECT"):
+				responses = append(responses, map[string]interface{}{"Item": map[string]interface{}{"id": map[string]interface{}{"S": param}}})
+			default:
+				responses = append(responses, map[string]interface{}{})
+			}
+		}
+		return stubResponse{body: map[string]interface{}{
+			"Responses":        responses,
+			"ConsumedCapacity": []interface{}{map[string]interface{}{"TableName": "tbltest", "CapacityUnits": float64(len(statements))}},
+		}}
+	})
+}
+
+// _unmarshallable is a value that fails to be marshalled to an attribute value.
+type _unmarshallable struct{}
+
+func (_unmarshallable) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return nil, errors.New("unmarshallable")
+}
+
+func TestExecBatch(t *testing.T) {
+	testName := "TestExecBatch"
+	var sizes []int
+	server := _batchStubServer(&sizes)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	var statements []Statement
+	for i := 0; i < 60; i++ {
+		id := fmt.Sprintf("%d", i)
+		switch i {
+		case 7:
+			id = "fail"
+		case 30, 31:
+			id = "throttle" + id
+		}
+		statements = append(statements, Statement{Query: `INSERT INTO "tbltest" VALUE {'id': ?}`, Args: []interface{}{id}})
+	}
+	statements = append(statements, Statement{Query: `SELECT * FROM "tbltest" WHERE id=?`, Args: []interface{}{"x"}, ConsistentRead: true})
+	statements = append(statements, Statement{Query: `DELETE FROM "tbltest" WHERE id=?`, Args: []interface{}{_unmarshallable{}}})
+
+	ctx, collector := WithCapacityCollector(context.Background())
+	results, err := ExecBatch(ctx, db, statements, func(opts *BatchOptions) {
+		opts.Concurrency = 2
+		opts.Retry = RetryPolicy{MaxAttempts: 3, MaxBackoff: 10 * time.Millisecond}
+	})
+	if !errors.Is(err, ErrBatchFailed) {
+		t.Fatalf("%s failed: expected ErrBatchFailed but received %v", testName, err)
+	}
+	if len(results) != len(statements) {
+		t.Fatalf("%s failed: expected %d results but received %d", testName, len(statements), len(results))
+	}
+	for i, result := range results {
+		var stmtErr *BatchStatementError
+		switch i {
+		case 7:
+			if !errors.As(result.Err, &stmtErr) || stmtErr.Code != "ConditionalCheckFailed" {
+				t.Fatalf("%s failed: expected ConditionalCheckFailed for statement %d but received %v", testName, i, result.Err)
+			}
+		case 60:
+			if result.Err != nil || result.Item["id"] != "x" {
+				t.Fatalf("%s failed: unexpected result %#v for statement %d", testName, result, i)
+			}
+		case 61:
+			if result.Err == nil {
+				t.Fatalf("%s failed: expected marshalling error for statement %d", testName, i)
+			}
+		default:
+			if result.Err != nil {
+				t.Fatalf("%s failed: unexpected error for statement %d: %s", testName, i, result.Err)
+			}
+		}
+	}
+	// 3 batches of writes (one retry of the throttled statements), 1 batch of read
+	total := 0
+	for _, size := range sizes {
+		if size > MaxBatchSize {
+			t.Fatalf("%s failed: batch of %d statements", testName, size)
+		}
+		total += size
+	}
+	if len(sizes) != 5 || total != 63 {
+		t.Fatalf("%s failed: unexpected batches %#v", testName, sizes)
+	}
+	if capacity, _ := collector.ConsumedCapacity(); capacity == nil || *capacity.CapacityUnits != 63 {
+		t.Fatalf("%s failed: expected 63 capacity units but received %#v", testName, capacity)
+	}
+
+	if results, err = ExecBatch(ctx, db, nil); err != nil || len(results) != 0 {
+		t.Fatalf("%s failed: unexpected %#v (error %v)", testName, results, err)
+	}
+}
+
+func TestExecBatch_retryExhausted(t *testing.T) {
+	testName := "TestExecBatch_retryExhausted"
+	var sizes []int
+	server := _batchStubServer(&sizes)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	statements := []Statement{{Query: `INSERT INTO "tbltest" VALUE {'id': ?}`, Args: []interface{}{"throttle"}}}
+	results, err := ExecBatch(context.Background(), db, statements, func(opts *BatchOptions) {
+		opts.Retry = RetryPolicy{MaxAttempts: 1}
+	})
+	var stmtErr *BatchStatementError
+	if !errors.Is(err, ErrBatchFailed) || !errors.As(results[0].Err, &stmtErr) || stmtErr.Code != "ThrottlingError" {
+		t.Fatalf("%s failed: expected ThrottlingError but received %v/%v", testName, err, results[0].Err)
+	}
+	if len(sizes) != 1 {
+		t.Fatalf("%s failed: expected %d call but received %d", testName, 1, len(sizes))
+	}
+}
@@ -0,0 +1,132 @@
+package godynamo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CapacityReporter is implemented by results that report the capacity consumed by DynamoDB to produce them.
+//
+// Results returned by database/sql do not expose the driver's results; attach a CapacityCollector to the context
+// instead (see WithCapacityCollector).
+//
+// @Available since v1.4.0
+type CapacityReporter interface {
+	// ConsumedCapacity returns the total capacity consumed so far, or nil if not available.
+	ConsumedCapacity() (*types.ConsumedCapacity, error)
+}
+
+// addConsumedCapacity returns the sum of two consumed capacities. TableName is kept only if both are of the same
+// table. Capacities of indexes are not summed.
+func addConsumedCapacity(a, b *types.ConsumedCapacity) *types.ConsumedCapacity {
+	if a == nil || b == nil {
+		if a == nil {
+			a = b
+		}
+		if a == nil {
+			return nil
+		}
+		return &types.ConsumedCapacity{TableName: a.TableName, CapacityUnits: a.CapacityUnits,
+			ReadCapacityUnits: a.ReadCapacityUnits, WriteCapacityUnits: a.WriteCapacityUnits}
+	}
+	addFloat := func(x, y *float64) *float64 {
+		if x == nil || y == nil {
+			if x == nil {
+				return y
+			}
+			return x
+		}
+		return aws.Float64(*x + *y)
+	}
+	sum := &types.ConsumedCapacity{
+		CapacityUnits:      addFloat(a.CapacityUnits, b.CapacityUnits),
+		ReadCapacityUnits:  addFloat(a.ReadCapacityUnits, b.ReadCapacityUnits),
+		WriteCapacityUnits: addFloat(a.WriteCapacityUnits, b.WriteCapacityUnits),
+	}
+	if aws.ToString(a.TableName) == aws.ToString(b.TableName) {
+		sum.TableName = a.TableName
+	}
+	return sum
+}
+
+/*----------------------------------------------------------------------*/
+
+type capacityCollectorKey struct{}
+
+// CapacityCollector sums the capacity consumed by all calls to DynamoDB made with a context, including all pages of
+// result sets and all statements of transactions.
+//
+// @Available since v1.4.0
+type CapacityCollector struct {
+	lock   sync.Mutex
+	total  *types.ConsumedCapacity
+	tables map[string]*types.ConsumedCapacity
+}
+
+// WithCapacityCollector returns a copy of ctx with a new CapacityCollector attached. Statements executed with the
+// returned context (e.g. via db.QueryContext or db.ExecContext) report the consumed capacity to the collector.
+// Transactions report to the collector attached to the context of db.BeginTx, as well as to the collectors attached
+// to the contexts of their statements.
+//
+// Example:
+//
+//	ctx, collector := godynamo.WithCapacityCollector(context.Background())
+//	rows, err := db.QueryContext(ctx, `SELECT * FROM "session"`)
+//	...
+//	capacity, _ := collector.ConsumedCapacity()
+//
+// @Available since v1.4.0
+func WithCapacityCollector(ctx context.Context) (context.Context, *CapacityCollector) {
+	collector := &CapacityCollector{tables: make(map[string]*types.ConsumedCapacity)}
+	return context.WithValue(ctx, capacityCollectorKey{}, collector), collector
+}
+
+// capacityCollectorFromContext returns the CapacityCollector attached to ctx, or nil if none.
+func capacityCollectorFromContext(ctx context.Context) *CapacityCollector {
+	if ctx == nil {
+		return nil
+	}
+	collector, _ := ctx.Value(capacityCollectorKey{}).(*CapacityCollector)
+	return collector
+}
+
+// add adds consumed capacities to the collector. It is safe to call add on a nil collector.
+func (c *CapacityCollector) add(capacities ...*types.ConsumedCapacity) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, capacity := range capacities {
+		if capacity == nil {
+			continue
+		}
+		c.total = addConsumedCapacity(c.total, capacity)
+		if tableName := aws.ToString(capacity.TableName); tableName != "" {
+			c.tables[tableName] = addConsumedCapacity(c.tables[tableName], capacity)
+		}
+	}
+}
+
+// ConsumedCapacity implements CapacityReporter/ConsumedCapacity.
+//
+// The returned value is the sum of all capacities reported to the collector, or nil if none was reported.
+func (c *CapacityCollector) ConsumedCapacity() (*types.ConsumedCapacity, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return addConsumedCapacity(c.total, nil), nil
+}
+
+// ConsumedCapacityByTable returns the capacities reported to the collector, summed per table.
+func (c *CapacityCollector) ConsumedCapacityByTable() map[string]*types.ConsumedCapacity {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := make(map[string]*types.ConsumedCapacity, len(c.tables))
+	for tableName, capacity := range c.tables {
+		result[tableName] = addConsumedCapacity(capacity, nil)
+	}
+	return result
+}
@@ -0,0 +1,183 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestAddConsumedCapacity(t *testing.T) {
+	testName := "TestAddConsumedCapacity"
+	a := &types.ConsumedCapacity{TableName: aws.String("t1"), CapacityUnits: aws.Float64(1), ReadCapacityUnits: aws.Float64(1)}
+	b := &types.ConsumedCapacity{TableName: aws.String("t1"), CapacityUnits: aws.Float64(2), WriteCapacityUnits: aws.Float64(2)}
+	c := &types.ConsumedCapacity{TableName: aws.String("t2"), CapacityUnits: aws.Float64(4)}
+
+	if addConsumedCapacity(nil, nil) != nil {
+		t.Fatalf("%s failed: sum of nil capacities must be nil", testName)
+	}
+	sum := addConsumedCapacity(a, b)
+	if aws.ToString(sum.TableName) != "t1" || *sum.CapacityUnits != 3 || *sum.ReadCapacityUnits != 1 || *sum.WriteCapacityUnits != 2 {
+		t.Fatalf("%s failed: unexpected sum %#v", testName, sum)
+	}
+	sum = addConsumedCapacity(sum, c)
+	if sum.TableName != nil || *sum.CapacityUnits != 7 {
+		t.Fatalf("%s failed: unexpected sum %#v", testName, sum)
+	}
+	if *a.CapacityUnits != 1 {
+		t.Fatalf("%s failed: operands must not be modified", testName)
+	}
+}
+
+func _capacityStubServer(numPages int) *stubDynamoDBServer {
+	return newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		switch op {
+		case "ExecuteTransaction":
+			return stubResponse{body: map[string]interface{}{
+				"Responses": []interface{}{map[string]interface{}{}, map[string]interface{}{}},
+				"ConsumedCapacity": []interface{}{
+					map[string]interface{}{"TableName": "t1", "CapacityUnits": 2},
+					map[string]interface{}{"TableName": "t2", "CapacityUnits": 3},
+				},
+			}}
+		case "ExecuteStatement":
+			page := 0
+			if token, ok := req["NextToken"].(string); ok {
+				_, _ = fmt.Sscanf(token, "page-%d", &page)
+			}
+			body := map[string]interface{}{
+				"Items":            []interface{}{map[string]interface{}{"id": map[string]interface{}{"S": fmt.Sprintf("%d", page)}}},
+				"ConsumedCapacity": map[string]interface{}{"TableName": "tbltest", "CapacityUnits": 0.5},
+			}
+			if page+1 < numPages {
+				body["NextToken"] = fmt.Sprintf("page-%d", page+1)
+			}
+			return stubResponse{body: body}
+		}
+		return stubResponse{}
+	})
+}
+
+func TestCapacityCollector_pages(t *testing.T) {
+	testName := "TestCapacityCollector_pages"
+	server := _capacityStubServer(3)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	ctx, collector := WithCapacityCollector(context.Background())
+	if count, err := _countRows(db, ctx); err != nil || count != 3 {
+		t.Fatalf("%s failed: expected 3 rows but received %d (error %v)", testName, count, err)
+	}
+	capacity, err := collector.ConsumedCapacity()
+	if err != nil || capacity == nil || *capacity.CapacityUnits != 1.5 || aws.ToString(capacity.TableName) != "tbltest" {
+		t.Fatalf("%s failed: unexpected consumed capacity %#v (error %v)", testName, capacity, err)
+	}
+
+	// statements executed without the collector are not counted
+	if _, err = _countRows(db, context.Background()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if capacity, _ = collector.ConsumedCapacity(); *capacity.CapacityUnits != 1.5 {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, 1.5, *capacity.CapacityUnits)
+	}
+}
+
+func TestCapacityCollector_tx(t *testing.T) {
+	testName := "TestCapacityCollector_tx"
+	server := _capacityStubServer(1)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	txCtx, txCollector := WithCapacityCollector(context.Background())
+	stmtCtx, stmtCollector := WithCapacityCollector(context.Background())
+	tx, err := db.BeginTx(txCtx, nil)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.ExecContext(stmtCtx, `INSERT INTO "t1" VALUE {'id': '1'}`); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Exec(`INSERT INTO "t2" VALUE {'id': '2'}`); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	if capacity, _ := txCollector.ConsumedCapacity(); capacity == nil || *capacity.CapacityUnits != 5 {
+		t.Fatalf("%s failed: expected total capacity 5 but received %#v", testName, capacity)
+	}
+	byTable := txCollector.ConsumedCapacityByTable()
+	if len(byTable) != 2 || *byTable["t1"].CapacityUnits != 2 || *byTable["t2"].CapacityUnits != 3 {
+		t.Fatalf("%s failed: unexpected capacity by table %#v", testName, byTable)
+	}
+	if capacity, _ := stmtCollector.ConsumedCapacity(); capacity == nil || *capacity.CapacityUnits != 2 {
+		t.Fatalf("%s failed: expected statement capacity 2 but received %#v", testName, capacity)
+	}
+}
+
+func TestCapacityReporter_raw(t *testing.T) {
+	testName := "TestCapacityReporter_raw"
+	server := _capacityStubServer(2)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+	sqlConn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = sqlConn.Close() }()
+
+	err = sqlConn.Raw(func(dc any) error {
+		conn := dc.(*Conn)
+		stmt, err := conn.Prepare(`INSERT INTO "tbltest" VALUE {'id': '1'}`)
+		if err != nil {
+			return err
+		}
+		result, err := stmt.(driver.StmtExecContext).ExecContext(context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		if capacity, err := result.(CapacityReporter).ConsumedCapacity(); err != nil || *capacity.CapacityUnits != 0.5 {
+			return fmt.Errorf("unexpected capacity %#v (error %v)", capacity, err)
+		}
+
+		stmt, err = conn.Prepare(`SELECT * FROM "tbltest"`)
+		if err != nil {
+			return err
+		}
+		rows, err := stmt.(driver.StmtQueryContext).QueryContext(context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rows.Close() }()
+		dest := make([]driver.Value, len(rows.Columns()))
+		for rows.Next(dest) == nil {
+		}
+		if capacity, err := rows.(CapacityReporter).ConsumedCapacity(); err != nil || *capacity.CapacityUnits != 1 {
+			return fmt.Errorf("unexpected capacity %#v (error %v)", capacity, err)
+		}
+
+		// results in a transaction are available only after commit
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, _ = conn.Prepare(`INSERT INTO "tbltest" VALUE {'id': '2'}`)
+		result, _ = stmt.(driver.StmtExecContext).ExecContext(context.Background(), nil)
+		if _, err = result.(CapacityReporter).ConsumedCapacity(); !errors.Is(err, ErrInTx) {
+			return fmt.Errorf("expected ErrInTx but received %v", err)
+		}
+		return tx.Rollback()
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
@@ -0,0 +1,45 @@
+package godynamo
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// DynamoDBAPI is the set of AWS DynamoDB operations used by the driver. *dynamodb.Client implements DynamoDBAPI, and
+// so can any wrapper (e.g. an instrumented client) or fake.
+//
+// Operations may be added to DynamoDBAPI as the driver uses more of the DynamoDB API. To stay forward-compatible,
+// implementations should embed a DynamoDBAPI (e.g. a *dynamodb.Client) and override only the operations they need:
+//
+//	type instrumentedClient struct {
+//		godynamo.DynamoDBAPI // e.g. dynamodb.New(opts)
+//	}
+//
+//	func (c *instrumentedClient) ExecuteStatement(ctx context.Context, params *dynamodb.ExecuteStatementInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ExecuteStatementOutput, error) {
+//		// instrumentation...
+//		return c.DynamoDBAPI.ExecuteStatement(ctx, params, optFns...)
+//	}
+//
+// @Available since v1.4.0
+type DynamoDBAPI interface {
+	BatchExecuteStatement(ctx context.Context, params *dynamodb.BatchExecuteStatementInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchExecuteStatementOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	ExecuteStatement(ctx context.Context, params *dynamodb.ExecuteStatementInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ExecuteStatementOutput, error)
+	ExecuteTransaction(ctx context.Context, params *dynamodb.ExecuteTransactionInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ExecuteTransactionOutput, error)
+	ListTables(ctx context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+}
+
+// ClientFactory creates the DynamoDBAPI used by the driver. opts are the options resolved from the Config (region,
+// credentials, endpoint, retryer, HTTP client, etc), so that a factory can, for example, create a *dynamodb.Client
+// with additional middleware via dynamodb.New(opts, ...), or wrap one.
+//
+// @Available since v1.4.0
+type ClientFactory func(opts dynamodb.Options) (DynamoDBAPI, error)
@@ -0,0 +1,95 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamoDBClient is a DynamoDBAPI that serves ExecuteStatement from memory, delegating other operations to the
+// embedded DynamoDBAPI.
+type fakeDynamoDBClient struct {
+	DynamoDBAPI
+	statements []string
+}
+
+func (c *fakeDynamoDBClient) ExecuteStatement(_ context.Context, params *dynamodb.ExecuteStatementInput, _ ...func(*dynamodb.Options)) (*dynamodb.ExecuteStatementOutput, error) {
+	c.statements = append(c.statements, *params.Statement)
+	return &dynamodb.ExecuteStatementOutput{Items: []map[string]types.AttributeValue{
+		{"id": &types.AttributeValueMemberS{Value: "fake"}},
+	}}, nil
+}
+
+func TestConfig_ClientFactory(t *testing.T) {
+	testName := "TestConfig_ClientFactory"
+	var region string
+	fake := &fakeDynamoDBClient{}
+	connector, err := NewConnector(Config{
+		Region:          "us-west-2",
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+		ClientFactory: func(opts dynamodb.Options) (DynamoDBAPI, error) {
+			region = opts.Region
+			fake.DynamoDBAPI = dynamodb.New(opts)
+			return fake, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if region != "us-west-2" {
+		t.Fatalf("%s failed: expected region %s passed to the factory but received %s", testName, "us-west-2", region)
+	}
+
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+	var id string
+	if err = db.QueryRow(`SELECT id FROM "tbltest"`).Scan(&id); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if id != "fake" || len(fake.statements) != 1 || fake.statements[0] != `SELECT id FROM "tbltest"` {
+		t.Fatalf("%s failed: query was not served by the fake client (id=%s, statements=%#v)", testName, id, fake.statements)
+	}
+}
+
+func TestConfig_ClientFactory_error(t *testing.T) {
+	testName := "TestConfig_ClientFactory_error"
+	errFactory := errors.New("factory error")
+	_, err := NewConnector(Config{Region: "us-east-1", ClientFactory: func(_ dynamodb.Options) (DynamoDBAPI, error) {
+		return nil, errFactory
+	}})
+	if err != errFactory {
+		t.Fatalf("%s failed: expected %v but received %v", testName, errFactory, err)
+	}
+}
+
+func TestConn_Client(t *testing.T) {
+	testName := "TestConn_Client"
+	db, err := sql.Open("godynamo", "Region=us-east-1;AkId=id;Secret_Key=secret")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	sqlConn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = sqlConn.Close() }()
+	err = sqlConn.Raw(func(dc any) error {
+		client, ok := dc.(*Conn).Client().(*dynamodb.Client)
+		if !ok {
+			return errors.New("client is not a *dynamodb.Client")
+		}
+		if client.Options().Region != "us-east-1" {
+			return errors.New("unexpected region " + client.Options().Region)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
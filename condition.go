@@ -0,0 +1,118 @@
+package godynamo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// OnConditionFailError makes INSERT, UPDATE and DELETE statements return a *ConditionFailedError when their
+	// condition check fails. It is the default of INSERT statements.
+	//
+	// @Available since v1.4.0
+	OnConditionFailError = "error"
+
+	// OnConditionFailIgnore makes INSERT, UPDATE and DELETE statements succeed with no affected row when their
+	// condition check fails. It is the default of UPDATE and DELETE statements.
+	//
+	// @Available since v1.4.0
+	OnConditionFailIgnore = "ignore"
+)
+
+// ConditionFailedError is returned when the condition check of an INSERT, UPDATE or DELETE statement fails, e.g.
+// when inserting an item which already exists, or when the WHERE clause of an UPDATE does not match the item.
+//
+// Example:
+//
+//	_, err := db.Exec(`UPDATE "tbl" SET version=? WHERE id=? AND version=? WITH OnConditionFail=error`, 2, "id1", 1)
+//	var condErr *godynamo.ConditionFailedError
+//	if errors.As(err, &condErr) {
+//		fmt.Println("conflicting version:", condErr.Item["version"])
+//	}
+//
+// @Available since v1.4.0
+type ConditionFailedError struct {
+	// Item is the current item, if it exists and DynamoDB returned it (ReturnValuesOnConditionCheckFailure=ALL_OLD).
+	// Numbers are returned as configured by the statement's number mode.
+	Item map[string]interface{}
+
+	// Err is the error returned by DynamoDB, e.g. a ConditionalCheckFailedException or a DuplicateItemException.
+	Err error
+}
+
+// Error implements error/Error.
+func (e *ConditionFailedError) Error() string {
+	return fmt.Sprintf("condition check failed: %s", e.Err)
+}
+
+// Unwrap returns the error returned by DynamoDB, so that IsAwsError still applies.
+func (e *ConditionFailedError) Unwrap() error {
+	return e.Err
+}
+
+// asConditionFailedError returns the *ConditionFailedError of err if it is a failed condition check, nil otherwise.
+func asConditionFailedError(err error, numberMode NumberMode) *ConditionFailedError {
+	var ccfErr *types.ConditionalCheckFailedException
+	if errors.As(err, &ccfErr) {
+		result := &ConditionFailedError{Err: err}
+		if len(ccfErr.Item) > 0 {
+			if item, uerr := numberMode.unmarshal(&types.AttributeValueMemberM{Value: ccfErr.Item}); uerr == nil {
+				result.Item, _ = item.(map[string]interface{})
+			}
+		}
+		return result
+	}
+	var dupErr *types.DuplicateItemException
+	if errors.As(err, &dupErr) {
+		return &ConditionFailedError{Err: err}
+	}
+	return nil
+}
+
+var reOnConditionFail = regexp.MustCompile(`(?is)\s+WITH\s+ON_?CONDITION_?FAIL\s*=\s*(\w+)\s*$`)
+
+// parseOnConditionFail parses and removes the "WITH OnConditionFail=error|ignore" clause of the statement, if any,
+// defaulting to defaultMode.
+func (s *StmtExecutable) parseOnConditionFail(defaultMode string) error {
+	s.onConditionFail = defaultMode
+	if match := reOnConditionFail.FindStringSubmatch(s.query); match != nil {
+		switch mode := strings.ToLower(match[1]); mode {
+		case OnConditionFailError, OnConditionFailIgnore:
+			s.onConditionFail = mode
+		default:
+			return fmt.Errorf("invalid OnConditionFail value: %s", match[1])
+		}
+		s.query = strings.TrimSpace(s.query[:len(s.query)-len(match[0])])
+	}
+	return nil
+}
+
+// conditionOptFns returns the options of the ExecuteStatement call: the current item is requested on failed
+// condition checks if the statement errors on them.
+func (s *StmtExecutable) conditionOptFns() []func(*dynamodb.ExecuteStatementInput) {
+	if s.onConditionFail != OnConditionFailError {
+		return nil
+	}
+	return []func(*dynamodb.ExecuteStatementInput){func(input *dynamodb.ExecuteStatementInput) {
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}}
+}
+
+// checkCondition applies the statement's OnConditionFail mode to err, the result of executing the statement: a
+// failed condition check is returned as nil (ignore mode) or as a *ConditionFailedError (error mode). Other errors
+// are returned as is.
+func (s *StmtExecutable) checkCondition(err error) error {
+	condErr := asConditionFailedError(err, s.numberMode())
+	if condErr == nil {
+		return err
+	}
+	if s.onConditionFail == OnConditionFailIgnore {
+		return nil
+	}
+	return condErr
+}
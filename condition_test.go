@@ -0,0 +1,116 @@
+package godynamo
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStmtExecutable_parseOnConditionFail(t *testing.T) {
+	testName := "TestStmtExecutable_parseOnConditionFail"
+	testData := []struct {
+		name      string
+		sql       string
+		mode      string
+		afterSql  string
+		mustError bool
+	}{
+		{name: "insert_default", sql: `INSERT INTO "tbl" VALUE {'id': ?}`, mode: OnConditionFailError, afterSql: `INSERT INTO "tbl" VALUE {'id': ?}`},
+		{name: "insert_ignore", sql: `INSERT INTO "tbl" VALUE {'id': ?} WITH OnConditionFail=ignore`, mode: OnConditionFailIgnore, afterSql: `INSERT INTO "tbl" VALUE {'id': ?}`},
+		{name: "update_default", sql: `UPDATE "tbl" SET a=? WHERE id=?`, mode: OnConditionFailIgnore, afterSql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL OLD *`},
+		{name: "update_error", sql: `UPDATE "tbl" SET a=? WHERE id=? with on_condition_fail = ERROR`, mode: OnConditionFailError, afterSql: `UPDATE "tbl" SET a=? WHERE id=? RETURNING ALL OLD *`},
+		{name: "delete_error", sql: `DELETE FROM "tbl" WHERE id=? RETURNING ALL OLD * WITH OnConditionFail=error`, mode: OnConditionFailError, afterSql: `DELETE FROM "tbl" WHERE id=? RETURNING ALL OLD *`},
+		{name: "invalid", sql: `DELETE FROM "tbl" WHERE id=? WITH OnConditionFail=retry`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(&Conn{}, testCase.sql)
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			var stmt *StmtExecutable
+			switch v := s.(type) {
+			case *StmtInsert:
+				stmt = v.StmtExecutable
+			case *StmtUpdate:
+				stmt = v.StmtExecutable
+			case *StmtDelete:
+				stmt = v.StmtExecutable
+			}
+			if query := stmt.render(false); stmt.onConditionFail != testCase.mode || query != testCase.afterSql {
+				t.Fatalf("%s failed: unexpected mode %s and query <%s>", testName+"/"+testCase.name, stmt.onConditionFail, query)
+			}
+		})
+	}
+}
+
+func TestConditionFailedError(t *testing.T) {
+	testName := "TestConditionFailedError"
+	var requests []map[string]interface{}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		requests = append(requests, req)
+		statement := req["Statement"].(string)
+		switch {
+		case strings.HasPrefix(statement, "INSERT"):
+			return stubError(400, "DuplicateItemException", "Duplicate primary key exists in table")
+		case req["ReturnValuesOnConditionCheckFailure"] == "ALL_OLD":
+			resp := stubError(400, "ConditionalCheckFailedException", "The conditional request failed")
+			resp.body.(map[string]interface{})["Item"] = map[string]interface{}{
+				"id":      map[string]interface{}{"S": "id1"},
+				"version": map[string]interface{}{"N": "3"},
+			}
+			return resp
+		}
+		return stubError(400, "ConditionalCheckFailedException", "The conditional request failed")
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	var condErr *ConditionFailedError
+	_, err := db.Exec(`INSERT INTO "tbl" VALUE {'id': ?}`, "id1")
+	if !errors.As(err, &condErr) || condErr.Item != nil || !IsAwsError(err, "DuplicateItemException") {
+		t.Fatalf("%s failed: expected ConditionFailedError but received %v", testName, err)
+	}
+	result, err := db.Exec(`INSERT INTO "tbl" VALUE {'id': ?} WITH OnConditionFail=ignore`, "id1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n, _ := result.RowsAffected(); n != 0 {
+		t.Fatalf("%s failed: expected 0 affected row but received %d", testName, n)
+	}
+
+	result, err = db.Exec(`UPDATE "tbl" SET version=? WHERE id=? AND version=?`, 2, "id1", 1)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n, _ := result.RowsAffected(); n != 0 {
+		t.Fatalf("%s failed: expected 0 affected row but received %d", testName, n)
+	}
+	if _, ok := requests[len(requests)-1]["ReturnValuesOnConditionCheckFailure"]; ok {
+		t.Fatalf("%s failed: current item must not be requested in ignore mode", testName)
+	}
+
+	_, err = db.Exec(`UPDATE "tbl" SET version=? WHERE id=? AND version=? WITH OnConditionFail=error`, 2, "id1", 1)
+	if !errors.As(err, &condErr) || !IsAwsError(err, "ConditionalCheckFailedException") {
+		t.Fatalf("%s failed: expected ConditionFailedError but received %v", testName, err)
+	}
+	if condErr.Item["id"] != "id1" || condErr.Item["version"] != 3.0 {
+		t.Fatalf("%s failed: unexpected item %#v", testName, condErr.Item)
+	}
+	if statement := requests[len(requests)-1]["Statement"]; statement != `UPDATE "tbl" SET version=? WHERE id=? AND version=? RETURNING ALL OLD *` {
+		t.Fatalf("%s failed: unexpected statement %s", testName, statement)
+	}
+
+	_, err = db.Query(`DELETE FROM "tbl" WHERE id=? WITH OnConditionFail=error`, "id1")
+	if !errors.As(err, &condErr) || condErr.Item["version"] != 3.0 {
+		t.Fatalf("%s failed: expected ConditionFailedError but received %v", testName, err)
+	}
+}
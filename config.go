@@ -0,0 +1,305 @@
+package godynamo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/btnguyen2k/consu/reddo"
+)
+
+const (
+	// DefaultTimeout is the timeout applied to DynamoDB calls if TimeoutMs is not specified in the connection string.
+	//
+	// @Available since v1.4.0
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultWidenColumnsMaxItems is the maximum number of items fetched to widen the columns of a result set if
+	// Config.WidenColumnsMaxItems is not configured.
+	//
+	// @Available since v1.4.0
+	DefaultWidenColumnsMaxItems = 10000
+)
+
+// Config holds the settings used to connect to AWS DynamoDB. It covers everything that can be specified via the
+// connection string (DSN) and RegisterAWSConfig, and can be passed to NewConnector to use the driver with sql.OpenDB
+// without encoding settings into a string.
+//
+// @Available since v1.4.0
+type Config struct {
+	// Region is the AWS region, for example "us-east-1".
+	Region string
+
+	// AccessKeyID is the AWS access key ID.
+	AccessKeyID string
+
+	// SecretAccessKey is the AWS secret access key.
+	SecretAccessKey string
+
+	// SessionToken is the (optional) AWS session token, used together with AccessKeyID and SecretAccessKey for
+	// temporary credentials.
+	SessionToken string
+
+	// Profile is the (optional) shared config profile used to resolve credentials if AccessKeyID is not specified.
+	Profile string
+
+	// RoleArn is the (optional) ARN of the IAM role to assume.
+	RoleArn string
+
+	// ExternalID is the (optional) external ID used when assuming RoleArn.
+	ExternalID string
+
+	// RoleSessionName is the (optional) session name used when assuming RoleArn.
+	RoleSessionName string
+
+	// WebIdentityTokenFile is the (optional) path to a file containing a web identity token (e.g. an EKS service
+	// account token), which is exchanged for the credentials of RoleArn.
+	WebIdentityTokenFile string
+
+	// STSEndpoint is the (optional) AWS STS endpoint used to assume RoleArn.
+	STSEndpoint string
+
+	// Endpoint is the (optional) AWS DynamoDB endpoint, for example "http://localhost:8000".
+	Endpoint string
+
+	// Timeout is the timeout of each call to DynamoDB. If zero, no timeout is applied. ParseDSN sets it to DefaultTimeout
+	// if TimeoutMs is not specified.
+	Timeout time.Duration
+
+	// ResultSetTimeout is the (optional) timeout of reading a whole result set, including fetching all subsequent
+	// pages, counted from the moment the statement is executed. If zero, reading a result set is bounded only by the
+	// caller's context and Timeout of each page.
+	ResultSetTimeout time.Duration
+
+	// NumberMode specifies the Go type DynamoDB numbers are returned as in result sets. If empty, NumberModeFloat64
+	// is used. NumberMode can be overridden per SELECT statement with "WITH number_mode=<mode>".
+	NumberMode NumberMode
+
+	// WidenColumns, if true, makes the columns of result sets cover the attributes of the items of all pages, not only
+	// of the first page, by fetching all pages when the statement is executed. WidenColumns does not apply to
+	// statements with an explicit column list, nor to tables with a schema registered via RegisterTableSchema.
+	//
+	// WidenColumns is costly: the statement does not return until all pages are fetched, which reads the whole result
+	// set (and consumes its read capacity) even if only the first rows are used, and all pages are held in memory. It
+	// is limited to result sets of up to WidenColumnsMaxItems items: above the limit, no row is returned and the first
+	// call to Next fails with ErrWidenColumnsLimit. Register the schema of the table to get stable columns for larger
+	// result sets.
+	WidenColumns bool
+
+	// WidenColumnsMaxItems is the maximum number of items fetched to widen the columns of a result set (see
+	// WidenColumns). Reading a result set with more items fails with ErrWidenColumnsLimit. If zero,
+	// DefaultWidenColumnsMaxItems is used.
+	WidenColumnsMaxItems int
+
+	// PageTokenSecret is the (optional) secret used to sign the resume tokens of result sets (HMAC-SHA256), so that
+	// tampered tokens are rejected. If empty, tokens are not signed.
+	PageTokenSecret string
+
+	// Prefetch is the (optional) number of pages of result sets fetched ahead in background while rows are read. If
+	// zero, the next page is fetched only when all rows of the current page are read. Prefetch can be overridden per
+	// SELECT statement with "WITH Prefetch=<n>".
+	Prefetch int
+
+	// DenyScans, if true, refuses SELECT, UPDATE and DELETE statements whose WHERE clause has no equality condition on
+	// the partition key of the table (or index), with ErrScanDenied. Key schemas are fetched via DescribeTable and
+	// cached. A SELECT statement can opt out with "WITH AllowScan=true".
+	DenyScans bool
+
+	// RetryMode is the (optional) retry mode, either aws.RetryModeStandard (default) or aws.RetryModeAdaptive.
+	RetryMode aws.RetryMode
+
+	// Retry is the retry policy applied to failed calls to DynamoDB.
+	Retry RetryPolicy
+
+	// ThrottleRetry is the (optional) retry policy applied to throttled calls, e.g. calls failed with
+	// ProvisionedThroughputExceededException or ThrottlingException. If nil, Retry is used.
+	ThrottleRetry *RetryPolicy
+
+	// TxConflictRetry is the (optional) retry policy applied to calls failed with TransactionConflictException, or
+	// transactions canceled due to conflicts. If nil, such calls are not retried.
+	TxConflictRetry *RetryPolicy
+
+	// ProxyURL is the (optional) URL of the proxy used to call AWS services, for example "http://proxy:3128". If not
+	// specified, the proxy is taken from the environment (HTTPS_PROXY, HTTP_PROXY and NO_PROXY).
+	ProxyURL string
+
+	// CABundle is the (optional) path to a PEM file with additional certificate authorities to trust, for example
+	// the private CA of a VPC endpoint.
+	CABundle string
+
+	// ClientCert and ClientKey are the (optional) paths to the PEM encoded certificate and private key used for TLS
+	// client authentication.
+	ClientCert, ClientKey string
+
+	// MaxIdleConns and MaxIdleConnsPerHost (optional) override the idle connection limits of the HTTP transport.
+	MaxIdleConns, MaxIdleConnsPerHost int
+
+	// HTTPClientID references an HTTP client previously registered via RegisterHTTPClient.
+	HTTPClientID string
+
+	// HTTPClient is used to call AWS services if not nil. HTTPClient takes precedence over HTTPClientID. The timeout
+	// and transport settings of the Config do not apply to HTTPClient.
+	HTTPClient aws.HTTPClient
+
+	// AWSConfigID references an aws.Config previously registered via RegisterAWSConfig.
+	AWSConfigID string
+
+	// AWSConfig is used to create the DynamoDB client if not nil. AWSConfig takes precedence over AWSConfigID.
+	//
+	// Same as RegisterAWSConfig, the HTTPClient setting of AWSConfig does not apply.
+	AWSConfig *aws.Config
+
+	// ClientFactory is the (optional) factory used to create the DynamoDB client. If nil, a *dynamodb.Client is used.
+	ClientFactory ClientFactory
+}
+
+// parseConfig builds a Config from the parameters parsed from a connection string, falling back to
+// environment variables where applicable.
+func parseConfig(params map[string]string) Config {
+	timeoutMs := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool {
+		return val.(int64) >= 0
+	}, int64(DefaultTimeout/time.Millisecond), []string{"TIMEOUTMS"}, nil).(int64)
+	resultSetTimeoutMs := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool {
+		return val.(int64) >= 0
+	}, int64(0), []string{"RESULTSETTIMEOUTMS"}, nil).(int64)
+	widenColumnsMaxItems := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool {
+		return val.(int64) >= 0
+	}, int64(0), []string{"WIDENCOLUMNSMAXITEMS"}, nil).(int64)
+	prefetch := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool {
+		return val.(int64) >= 0
+	}, int64(0), []string{"PREFETCH"}, nil).(int64)
+	maxIdleConns := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool {
+		return val.(int64) >= 0
+	}, int64(0), []string{"MAXIDLECONNS"}, nil).(int64)
+	maxIdleConnsPerHost := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool {
+		return val.(int64) >= 0
+	}, int64(0), []string{"MAXIDLECONNSPERHOST"}, nil).(int64)
+	retryMode, err := aws.ParseRetryMode(parseParamValue(params, reddo.TypeString, nil, "", []string{"RETRYMODE", "RETRY_MODE"}, []string{"AWS_RETRY_MODE"}).(string))
+	if err != nil {
+		retryMode = ""
+	}
+	numberMode, err := ParseNumberMode(parseParamValue(params, reddo.TypeString, nil, "", []string{"NUMBERMODE", "NUMBER_MODE"}, nil).(string))
+	if err != nil || numberMode == NumberModeFloat64 {
+		numberMode = ""
+	}
+	cfg := Config{
+		Region:          parseParamValue(params, reddo.TypeString, nil, "", []string{"REGION"}, []string{"AWS_REGION"}).(string),
+		AccessKeyID:     parseParamValue(params, reddo.TypeString, nil, "", []string{"AKID"}, []string{"AWS_ACCESS_KEY_ID", "AWS_AKID"}).(string),
+		SecretAccessKey: parseParamValue(params, reddo.TypeString, nil, "", []string{"SECRET_KEY", "SECRETKEY"}, []string{"AWS_SECRET_KEY", "AWS_SECRET_ACCESS_KEY"}).(string),
+		SessionToken:    parseParamValue(params, reddo.TypeString, nil, "", []string{"SESSION_TOKEN", "SESSIONTOKEN"}, []string{"AWS_SESSION_TOKEN"}).(string),
+		// environment variables AWS_PROFILE, AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE are handled by the default
+		// credential chain, hence they are not read here
+		Profile:              params["PROFILE"],
+		RoleArn:              params["ROLEARN"],
+		ExternalID:           params["EXTERNALID"],
+		RoleSessionName:      params["ROLESESSIONNAME"],
+		WebIdentityTokenFile: params["WEBIDENTITYTOKENFILE"],
+		STSEndpoint:          parseParamValue(params, reddo.TypeString, nil, "", []string{"STSENDPOINT", "STS_ENDPOINT"}, []string{"AWS_ENDPOINT_URL_STS"}).(string),
+		Endpoint:             parseParamValue(params, reddo.TypeString, nil, "", []string{"ENDPOINT"}, []string{"AWS_DYNAMODB_ENDPOINT"}).(string),
+		Timeout:              time.Duration(timeoutMs) * time.Millisecond,
+		ResultSetTimeout:     time.Duration(resultSetTimeoutMs) * time.Millisecond,
+		NumberMode:           numberMode,
+		WidenColumns:         parseParamValue(params, reddo.TypeBool, nil, false, []string{"WIDENCOLUMNS"}, nil).(bool),
+		WidenColumnsMaxItems: int(widenColumnsMaxItems),
+		PageTokenSecret:      params["PAGETOKENSECRET"],
+		Prefetch:             int(prefetch),
+		DenyScans:            parseParamValue(params, reddo.TypeBool, nil, false, []string{"DENYSCANS"}, nil).(bool),
+		AWSConfigID:          params[AWSConfigID],
+		RetryMode:            retryMode,
+		ProxyURL:             params["PROXYURL"],
+		CABundle:             parseParamValue(params, reddo.TypeString, nil, "", []string{"CABUNDLE"}, []string{"AWS_CA_BUNDLE"}).(string),
+		ClientCert:           params["CLIENTCERT"],
+		ClientKey:            params["CLIENTKEY"],
+		MaxIdleConns:         int(maxIdleConns),
+		MaxIdleConnsPerHost:  int(maxIdleConnsPerHost),
+		HTTPClientID:         params["HTTPCLIENTID"],
+	}
+	cfg.Retry, _ = parseRetryPolicy(params, "", []string{"AWS_MAX_ATTEMPTS"})
+	if policy, ok := parseRetryPolicy(params, "THROTTLE", nil); ok {
+		cfg.ThrottleRetry = &policy
+	}
+	if policy, ok := parseRetryPolicy(params, "TXCONFLICT", nil); ok {
+		cfg.TxConflictRetry = &policy
+	}
+	return cfg
+}
+
+// widenColumnsMaxItems returns the configured WidenColumnsMaxItems, or DefaultWidenColumnsMaxItems if not configured.
+func (cfg Config) widenColumnsMaxItems() int {
+	if cfg.WidenColumnsMaxItems <= 0 {
+		return DefaultWidenColumnsMaxItems
+	}
+	return cfg.WidenColumnsMaxItems
+}
+
+// timeout returns the configured timeout, zero meaning no timeout.
+func (cfg Config) timeout() time.Duration {
+	if cfg.Timeout < 0 {
+		return 0
+	}
+	return cfg.Timeout
+}
+
+// dynamodbOptions builds the dynamodb.Options from the settings in the Config. Credentials are resolved only if
+// withCredentials is true.
+func (cfg Config) dynamodbOptions(ctx context.Context, withCredentials bool) (dynamodb.Options, error) {
+	httpClient, err := cfg.httpClient()
+	if err != nil {
+		return dynamodb.Options{}, err
+	}
+	opts := dynamodb.Options{
+		HTTPClient: httpClient,
+		Region:     cfg.Region,
+	}
+	if withCredentials {
+		credsProvider, err := cfg.credentialsProvider(ctx, httpClient)
+		if err != nil {
+			return opts, err
+		}
+		opts.Credentials = credsProvider
+	}
+	if retryer := cfg.retryer(); retryer != nil {
+		opts.Retryer = retryer
+	}
+	if cfg.Endpoint != "" {
+		opts.BaseEndpoint = aws.String(cfg.Endpoint)
+		if strings.HasPrefix(cfg.Endpoint, "http://") {
+			opts.EndpointOptions.DisableHTTPS = true
+		}
+	}
+	return opts, nil
+}
+
+// newClient creates a new DynamoDB client from the settings in the Config, using the ClientFactory if specified.
+func (cfg Config) newClient(ctx context.Context) (DynamoDBAPI, error) {
+	client, err := cfg.newDynamoDBClient(ctx)
+	if err != nil || cfg.ClientFactory == nil {
+		return client, err
+	}
+	return cfg.ClientFactory(client.Options())
+}
+
+// newDynamoDBClient creates a new *dynamodb.Client from the settings in the Config.
+func (cfg Config) newDynamoDBClient(ctx context.Context) (*dynamodb.Client, error) {
+	conf := cfg.AWSConfig
+	if conf == nil && cfg.AWSConfigID != "" {
+		awsConfigLock.RLock()
+		registeredConf, ok := awsConfig[cfg.AWSConfigID]
+		awsConfigLock.RUnlock()
+		if !ok {
+			return nil, ErrUnknownAWSConfigID
+		}
+		conf = &registeredConf
+	}
+	// credentials supplied by aws.Config take precedence, no need to resolve them from the Config
+	opts, err := cfg.dynamodbOptions(ctx, conf == nil || conf.Credentials == nil)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		return dynamodb.NewFromConfig(*conf, mergeDynamoDBOptions(opts)), nil
+	}
+	return dynamodb.New(opts), nil
+}
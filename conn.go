@@ -19,8 +19,34 @@ var (
 	ErrNoTx           = errors.New("no transaction is in progress")
 	ErrTxCommitting   = errors.New("transaction is being committed")
 	ErrTxRollingBack  = errors.New("transaction is being rolled back")
+
+	// ErrTxMixedStatements is returned when adding an UPSERT/REPLACE statement to a transaction holding PartiQL
+	// statements (INSERT, UPDATE or DELETE), or vice versa: DynamoDB executes them with different APIs
+	// (TransactWriteItems and ExecuteTransaction), and PartiQL statements are not translated into TransactWriteItems.
+	// Use separate transactions, or express the write with PartiQL statements only (e.g. UPDATE instead of UPSERT).
+	//
+	// @Available since v1.4.0
+	ErrTxMixedStatements = errors.New("UPSERT/REPLACE statements can not be mixed with PartiQL statements in a transaction")
+
+	// ErrTxReadOnly is returned when executing a statement other than SELECT in a read-only transaction.
+	//
+	// @Available since v1.4.0
+	ErrTxReadOnly = errors.New("only SELECT statements are allowed in read-only transactions")
+
+	// ErrRowsAffectedUnavailable is returned by RowsAffected of a committed statement with RETURNING clause for which
+	// DynamoDB returned no item, so that the number of affected rows is not known.
+	//
+	// @Available since v1.4.0
+	ErrRowsAffectedUnavailable = errors.New("number of affected rows is not available")
 )
 
+// errQueryInTx is returned by Query of statements executed in transactions: database/sql closes the rows of tx.Query
+// when committing, before the results of the statements are available.
+var errQueryInTx = fmt.Errorf("%w: use Exec in transactions, see TxResultCollector", ErrInTx)
+
+// maxTxStatements is the maximum number of statements DynamoDB executes in a single transaction.
+const maxTxStatements = 100
+
 type txMode int
 
 const (
@@ -32,47 +58,94 @@ const (
 
 // txStmt holds a statement to be executed in a transaction.
 type txStmt struct {
+	ctx    context.Context // context the statement was executed with
 	stmt   *Stmt
 	values []driver.NamedValue
+	put    *types.Put // if not nil, the statement is executed as a Put of TransactWriteItems
 	output *dynamodb.ExecuteStatementOutput
+	err    error // error committing the transaction, if failed
 }
 
 type statement struct {
-	ctx    context.Context
-	client *dynamodb.Client
-	limit  int32
-	input  *dynamodb.ExecuteStatementInput
-	output *dynamodb.ExecuteStatementOutput
+	ctx              context.Context      // caller's context, used to fetch subsequent pages
+	started          time.Time            // time the statement was executed
+	timeout          time.Duration        // timeout of each call to DynamoDB
+	resultSetTimeout time.Duration        // timeout of reading the whole result set, counted from started
+	numberMode       NumberMode           // Go type numbers are returned as
+	widenColumns     bool                 // if true, all pages are fetched to compute the column list
+	widenMaxItems    int                  // maximum number of items fetched to widen the columns
+	pageTokenSecret  []byte               // secret used to sign resume tokens, if not empty
+	prefetch         int                  // number of pages fetched ahead in background
+	scanInput        *dynamodb.ScanInput  // if not nil, the statement is executed with the native Scan API
+	queryInput       *dynamodb.QueryInput // if not nil, the statement is executed with the native Query API
+	client           DynamoDBAPI
+	limit            int32
+	input            *dynamodb.ExecuteStatementInput
+	output           *dynamodb.ExecuteStatementOutput
+	txErr            error // error committing the transaction the statement belongs to, if failed
 }
 type statementOutputWrapper func() *statement
 
-// Conn is AWS DynamoDB implementation of driver.Conn.
-type Conn struct {
-	client     *dynamodb.Client // AWS DynamoDB client
-	timeout    time.Duration
-	lock       sync.Mutex
-	tx         *Tx
-	txMode     txMode
-	txStmtList []*txStmt
+// native returns true if the statement is executed with the native Scan or Query API instead of ExecuteStatement.
+func (s *statement) native() bool {
+	return s.scanInput != nil || s.queryInput != nil
 }
 
-func (c *Conn) newContext() context.Context {
-	ctx, cancelFunc := context.WithTimeout(context.Background(), c.timeout)
-	go func() {
-		time.Sleep(c.timeout)
-		cancelFunc()
-	}()
-	return ctx
+// consumedCapacity returns the capacity consumed by the statement, or nil if not available.
+func (s *statement) consumedCapacity() *types.ConsumedCapacity {
+	if s == nil || s.output == nil {
+		return nil
+	}
+	return s.output.ConsumedCapacity
+}
+
+// consumedCapacity returns the capacity consumed by the wrapped statement, or nil if not available.
+func (fn statementOutputWrapper) consumedCapacity() *types.ConsumedCapacity {
+	if fn == nil {
+		return nil
+	}
+	return fn().consumedCapacity()
 }
 
-func (c *Conn) ensureContext(ctx context.Context) context.Context {
+// Conn is AWS DynamoDB implementation of driver.Conn.
+type Conn struct {
+	client           DynamoDBAPI   // AWS DynamoDB client
+	timeout          time.Duration // timeout of each call to DynamoDB
+	resultSetTimeout time.Duration // timeout of reading a whole result set, zero means no timeout
+	numberMode       NumberMode    // default number mode of result sets
+	widenColumns     bool          // if true, columns of result sets cover the attributes of all pages
+	widenMaxItems    int           // maximum number of items fetched to widen the columns of a result set
+	pageTokenSecret  []byte        // secret used to sign resume tokens, if not empty
+	prefetch         int           // default number of pages of result sets fetched ahead
+	denyScans        bool          // if true, statements that would scan a table are refused
+	txConflictRetry  *RetryPolicy  // retry policy of transactions canceled due to conflicts, nil means no retry
+	keySchemas       *keySchemaCache
+	lock             sync.Mutex
+	tx               *Tx
+	txMode           txMode
+	txReadOnly       bool // if true, the ongoing transaction only holds SELECT statements
+	txStmtList       []*txStmt
+}
+
+// withTimeout returns a copy of ctx (or context.Background() if ctx is nil) which is cancelled after timeout. If
+// timeout is not positive, ctx is only made cancellable.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
 	if ctx == nil {
-		ctx = c.newContext()
+		ctx = context.Background()
 	}
-	return ctx
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// requestContext derives the context of a single call to DynamoDB from the caller's context, applying the
+// per-request timeout.
+func (c *Conn) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, c.timeout)
 }
 
-func (c *Conn) commit() error {
+func (c *Conn) commit(ctx context.Context) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if c.tx == nil {
@@ -88,6 +161,7 @@ func (c *Conn) commit() error {
 	defer func() {
 		c.tx = nil
 		c.txMode = txNone
+		c.txReadOnly = false
 		c.txStmtList = nil
 	}()
 
@@ -96,6 +170,64 @@ func (c *Conn) commit() error {
 		return nil
 	}
 
+	if ctx != nil && ctx.Err() != nil {
+		// the transaction is not sent, its outcome is known
+		return c.txFailed(ctx.Err())
+	}
+	if c.txStmtList[0].put != nil {
+		return c.txFailed(c.commitWrite(ctx, c.commitWriteItems))
+	}
+	txStmts, err := c.txStatements()
+	if err != nil {
+		return c.txFailed(err)
+	}
+	send := func(ctx context.Context, token string, optFns ...func(*dynamodb.Options)) error {
+		return c.commitStatements(ctx, txStmts, token, optFns...)
+	}
+	if c.txReadOnly {
+		// no token: a read-only transaction has no effect to protect from being executed twice, and DynamoDB
+		// would return the items read by an earlier commit with the same token
+		return c.txFailed(send(ctx, ""))
+	}
+	return c.txFailed(c.commitWrite(ctx, send))
+}
+
+// commitWrite commits a transaction with write statements via send, which sends the transaction with the given
+// idempotency token.
+//
+// If the token was generated and TxConflictRetry is configured, transactions canceled due to conflicts are retried
+// here instead of by the AWS SDK, with a new token per attempt: the canceled attempt must not be taken for an earlier
+// execution of the retried one.
+func (c *Conn) commitWrite(ctx context.Context, send func(context.Context, string, ...func(*dynamodb.Options)) error) error {
+	token := c.tx.token
+	if c.tx.tokenSupplied || c.txConflictRetry == nil {
+		return txCommitError(token, send(ctx, token))
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	backoff := c.txConflictRetry.backoff()
+	for attempt := 1; ; attempt++ {
+		err := send(ctx, token, withoutTxConflictRetry)
+		if err == nil || attempt >= c.txConflictRetry.maxAttempts() || !isTxConflictError(err) {
+			return txCommitError(token, err)
+		}
+		delay, delayErr := backoff.BackoffDelay(attempt, err)
+		if delayErr != nil {
+			return delayErr
+		}
+		select {
+		case <-ctx.Done():
+			// the last attempt was canceled, the outcome is known
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		token = newTxToken()
+	}
+}
+
+// txStatements builds the statements of the ongoing transaction to send via ExecuteTransaction.
+func (c *Conn) txStatements() ([]types.ParameterizedStatement, error) {
 	txStmts := make([]types.ParameterizedStatement, len(c.txStmtList))
 	for i, txStmt := range c.txStmtList {
 		params := make([]types.AttributeValue, len(txStmt.values))
@@ -103,23 +235,48 @@ func (c *Conn) commit() error {
 		for j, v := range txStmt.values {
 			params[j], err = ToAttributeValue(v.Value)
 			if err != nil {
-				return fmt.Errorf("error marshalling parameter %d-th for statement <%s>: %s", j+1, txStmt.stmt.query, err)
+				return nil, fmt.Errorf("error marshalling parameter %d-th for statement <%s>: %s", j+1, txStmt.stmt.query, err)
 			}
 		}
-		txStmts[i] = types.ParameterizedStatement{Statement: &txStmt.stmt.query, Parameters: params}
+		txStmts[i] = types.ParameterizedStatement{Statement: aws.String(txStmt.stmt.render(true)), Parameters: params}
+		if !reSelect.MatchString(txStmt.stmt.query) {
+			// the current item is reported by TxCanceledError if the condition check fails
+			txStmts[i].ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+		}
 	}
+	return txStmts, nil
+}
+
+// commitStatements commits a transaction of PartiQL statements via ExecuteTransaction, with token as idempotency
+// token if not empty.
+func (c *Conn) commitStatements(ctx context.Context, txStmts []types.ParameterizedStatement, token string, optFns ...func(*dynamodb.Options)) error {
 	input := &dynamodb.ExecuteTransactionInput{
 		TransactStatements:     txStmts,
 		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 	}
-	outputExecuteTransaction, err := c.client.ExecuteTransaction(c.newContext(), input)
+	if token != "" {
+		input.ClientRequestToken = aws.String(token)
+	} else {
+		// prevent the AWS SDK from generating a token
+		optFns = append(optFns, func(o *dynamodb.Options) { o.IdempotencyTokenProvider = nil })
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	outputExecuteTransaction, err := c.client.ExecuteTransaction(ctx, input, optFns...)
 	if err == nil {
+		txCollector := capacityCollectorFromContext(ctx)
+		for i := range outputExecuteTransaction.ConsumedCapacity {
+			txCollector.add(&outputExecuteTransaction.ConsumedCapacity[i])
+		}
 		for i, txStmt := range c.txStmtList {
 			txStmt.output = &dynamodb.ExecuteStatementOutput{ResultMetadata: outputExecuteTransaction.ResultMetadata}
 			if len(outputExecuteTransaction.ConsumedCapacity) > i {
 				txStmt.output.ConsumedCapacity = &outputExecuteTransaction.ConsumedCapacity[i]
+				if stmtCollector := capacityCollectorFromContext(txStmt.ctx); stmtCollector != txCollector {
+					stmtCollector.add(txStmt.output.ConsumedCapacity)
+				}
 			}
-			if len(outputExecuteTransaction.Responses) > i {
+			if len(outputExecuteTransaction.Responses) > i && outputExecuteTransaction.Responses[i].Item != nil {
 				txStmt.output.Items = []map[string]types.AttributeValue{outputExecuteTransaction.Responses[i].Item}
 			}
 		}
@@ -127,6 +284,50 @@ func (c *Conn) commit() error {
 	return err
 }
 
+// txFailed records err, the error committing the transaction, on the statements of the transaction. A
+// TransactionCanceledException is returned as a *TxCanceledError, and each statement gets its cancellation reason.
+func (c *Conn) txFailed(err error) error {
+	if err == nil {
+		return nil
+	}
+	if canceledErr := asTxCanceledError(err, c.txStmtList); canceledErr != nil {
+		for i, txStmt := range c.txStmtList {
+			txStmt.err = canceledErr.Statements[i]
+		}
+		return canceledErr
+	}
+	for _, txStmt := range c.txStmtList {
+		txStmt.err = err
+	}
+	return err
+}
+
+// commitWriteItems commits a transaction of UPSERT/REPLACE statements via TransactWriteItems.
+func (c *Conn) commitWriteItems(ctx context.Context, token string, optFns ...func(*dynamodb.Options)) error {
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems:          make([]types.TransactWriteItem, len(c.txStmtList)),
+		ClientRequestToken:     aws.String(token),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	for i, txStmt := range c.txStmtList {
+		input.TransactItems[i] = types.TransactWriteItem{Put: txStmt.put}
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	output, err := c.client.TransactWriteItems(ctx, input, optFns...)
+	if err == nil {
+		// consumed capacity is reported per table, not per statement
+		txCollector := capacityCollectorFromContext(ctx)
+		for i := range output.ConsumedCapacity {
+			txCollector.add(&output.ConsumedCapacity[i])
+		}
+		for _, txStmt := range c.txStmtList {
+			txStmt.output = &dynamodb.ExecuteStatementOutput{ResultMetadata: output.ResultMetadata}
+		}
+	}
+	return err
+}
+
 func (c *Conn) rollback() error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -143,6 +344,7 @@ func (c *Conn) rollback() error {
 	defer func() {
 		c.tx = nil
 		c.txMode = txNone
+		c.txReadOnly = false
 		c.txStmtList = nil
 	}()
 	return nil
@@ -151,16 +353,29 @@ func (c *Conn) rollback() error {
 // executeContext executes a PartiQL query and returns the result output. The
 // context must remain valid while the query results are read. executeContext
 // returns a function in order to support Transactions, which do not have a
-// result until the Transaction is committed.
-func (c *Conn) executeContext(ctx context.Context, stmt *Stmt, values []driver.NamedValue) (statementOutputWrapper, error) {
+// result until the Transaction is committed. optFns, if any, customize the
+// input of the call to DynamoDB (not applicable in transactions).
+func (c *Conn) executeContext(ctx context.Context, stmt *Stmt, values []driver.NamedValue, optFns ...func(*dynamodb.ExecuteStatementInput)) (statementOutputWrapper, error) {
 	//fmt.Printf("[DEBUG] executeContext: in-tx %5v - %s\n", c.tx != nil, stmt.query)
 	if c.txMode == txStarted {
 		// transaction has started and not yet committed or rolled back
 		// --> can add more statements to the transaction
-		txStmt := txStmt{stmt: stmt, values: values}
+		if len(c.txStmtList) > 0 && c.txStmtList[0].put != nil {
+			return func() *statement { return nil }, ErrTxMixedStatements
+		}
+		if c.txReadOnly {
+			if !reSelect.MatchString(stmt.query) {
+				return func() *statement { return nil }, ErrTxReadOnly
+			}
+			if len(c.txStmtList) >= maxTxStatements {
+				return func() *statement { return nil }, fmt.Errorf("a transaction can not have more than %d statements", maxTxStatements)
+			}
+		}
+		txStmt := txStmt{ctx: ctx, stmt: stmt, values: values}
 		c.txStmtList = append(c.txStmtList, &txStmt)
+		txResultCollectorFromContext(ctx).add(&txStmt)
 		return func() *statement {
-			return &statement{output: txStmt.output}
+			return &statement{ctx: ctx, numberMode: stmt.numberMode(), output: txStmt.output, txErr: txStmt.err}
 		}, ErrInTx
 	}
 	if c.txMode != txNone {
@@ -181,7 +396,7 @@ func (c *Conn) executeContext(ctx context.Context, stmt *Stmt, values []driver.N
 	}
 
 	input := &dynamodb.ExecuteStatementInput{
-		Statement:              &stmt.query,
+		Statement:              aws.String(stmt.render(false)),
 		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 		Limit:                  stmt.limit,
 	}
@@ -193,23 +408,106 @@ func (c *Conn) executeContext(ctx context.Context, stmt *Stmt, values []driver.N
 	} else if consistentRead, ok = stmt.withOpts["CONSISTENTREAD"]; ok {
 		input.ConsistentRead = aws.Bool(consistentRead.FirstBool())
 	}
+	for _, fn := range optFns {
+		fn(input)
+	}
 
 	var limitNumItems int32 = 0
 	if stmt.limit != nil {
 		limitNumItems = *stmt.limit
 	}
-	output, err := c.client.ExecuteStatement(c.ensureContext(ctx), input)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	started := time.Now()
+	reqCtx, cancel := withTimeout(ctx, c.timeout)
+	defer cancel()
+	output, err := c.client.ExecuteStatement(reqCtx, input)
+	if output != nil {
+		capacityCollectorFromContext(ctx).add(output.ConsumedCapacity)
+	}
 	return func() *statement {
 		return &statement{
-			ctx:    ctx,
-			client: c.client,
-			input:  input,
-			limit:  limitNumItems,
-			output: output,
+			ctx:              ctx,
+			started:          started,
+			timeout:          c.timeout,
+			resultSetTimeout: c.resultSetTimeout,
+			numberMode:       stmt.numberMode(),
+			widenColumns:     c.widenColumns,
+			widenMaxItems:    c.widenMaxItems,
+			pageTokenSecret:  c.pageTokenSecret,
+			prefetch:         stmt.prefetch(),
+			client:           c.client,
+			input:            input,
+			limit:            limitNumItems,
+			output:           output,
 		}
 	}, err
 }
 
+// executePut executes a PutItem request, or adds it to the ongoing transaction as a Put of TransactWriteItems.
+func (c *Conn) executePut(ctx context.Context, stmt *Stmt, input *dynamodb.PutItemInput) (statementOutputWrapper, error) {
+	if c.txMode == txStarted {
+		if c.txReadOnly {
+			return nil, ErrTxReadOnly
+		}
+		if len(c.txStmtList) > 0 && c.txStmtList[0].put == nil {
+			return nil, ErrTxMixedStatements
+		}
+		if input.ReturnValues != "" && input.ReturnValues != types.ReturnValueNone {
+			return nil, errors.New("RETURNING is not supported in transactions")
+		}
+		txStmt := txStmt{ctx: ctx, stmt: stmt, put: &types.Put{TableName: input.TableName, Item: input.Item}}
+		c.txStmtList = append(c.txStmtList, &txStmt)
+		txResultCollectorFromContext(ctx).add(&txStmt)
+		return func() *statement {
+			return &statement{ctx: ctx, numberMode: stmt.numberMode(), output: txStmt.output, txErr: txStmt.err}
+		}, ErrInTx
+	}
+	if c.txMode != txNone {
+		return nil, ErrInvalidTxStage
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	started := time.Now()
+	reqCtx, cancel := c.requestContext(ctx)
+	defer cancel()
+	output, err := c.client.PutItem(reqCtx, input)
+	var result *dynamodb.ExecuteStatementOutput
+	if output != nil {
+		capacityCollectorFromContext(ctx).add(output.ConsumedCapacity)
+		result = &dynamodb.ExecuteStatementOutput{ConsumedCapacity: output.ConsumedCapacity, ResultMetadata: output.ResultMetadata}
+		if len(output.Attributes) > 0 {
+			result.Items = []map[string]types.AttributeValue{output.Attributes}
+		}
+	}
+	return func() *statement {
+		return &statement{
+			ctx:        ctx,
+			started:    started,
+			timeout:    c.timeout,
+			numberMode: stmt.numberMode(),
+			client:     c.client,
+			output:     result,
+		}
+	}, err
+}
+
+// Client returns the DynamoDB client used by the connection, which can be used to make native calls to DynamoDB,
+// for example:
+//
+//	err := sqlConn.Raw(func(dc any) error {
+//		client := dc.(*godynamo.Conn).Client().(*dynamodb.Client)
+//		...
+//	})
+//
+// @Available since v1.4.0
+func (c *Conn) Client() DynamoDBAPI {
+	return c.client
+}
+
 // Prepare implements driver.Conn/Prepare.
 func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 	return c.PrepareContext(context.Background(), query)
@@ -241,18 +539,65 @@ func (c *Conn) Begin() (driver.Tx, error) {
 // BeginTx implements driver.Conn/BeginTx.
 //
 // @Available since v0.2.0
-func (c *Conn) BeginTx(_ context.Context, _ driver.TxOptions) (driver.Tx, error) {
+//
+// Since v1.4.0, ctx is also used to commit the transaction.
+//
+// Since v1.4.0, if opts.ReadOnly is true, the transaction only accepts SELECT statements reading single items by
+// their keys (up to 100), which are committed as a single ExecuteTransaction; their rows are available once the
+// transaction is committed, as a consistent snapshot.
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if c.tx == nil {
-		c.tx = &Tx{conn: c}
+		c.tx = &Tx{conn: c, ctx: ctx}
+		c.tx.token, c.tx.tokenSupplied = txTokenFromContext(ctx)
 		c.txMode = txStarted
+		c.txReadOnly = opts.ReadOnly
 		c.txStmtList = make([]*txStmt, 0)
 		return c.tx, nil
 	}
 	return c.tx, ErrInTx
 }
 
+// Ping implements driver.Pinger/Ping.
+//
+// Ping issues a cheap authenticated call (ListTables with Limit=1) to verify that the endpoint is reachable and the
+// credentials are valid.
+//
+// @Available since v1.4.0
+func (c *Conn) Ping(ctx context.Context) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	_, err := c.client.ListTables(ctx, &dynamodb.ListTablesInput{Limit: aws.Int32(1)})
+	return err
+}
+
+// ResetSession implements driver.SessionResetter/ResetSession.
+//
+// ResetSession discards any transaction state left over on the connection before it is reused.
+//
+// @Available since v1.4.0
+func (c *Conn) ResetSession(_ context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx = nil
+	c.txMode = txNone
+	c.txReadOnly = false
+	c.txStmtList = nil
+	return nil
+}
+
+// IsValid implements driver.Validator/IsValid.
+//
+// A connection is not valid if it is left in the middle of committing or rolling back a transaction.
+//
+// @Available since v1.4.0
+func (c *Conn) IsValid() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.txMode != txCommitting && c.txMode != txRollingBack
+}
+
 // CheckNamedValue implements driver.NamedValueChecker/CheckNamedValue.
 func (c *Conn) CheckNamedValue(_ *driver.NamedValue) error {
 	// since DynamoDB is document db, it accepts any value types
@@ -0,0 +1,83 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestConn_Ping(t *testing.T) {
+	testName := "TestConn_Ping"
+	var limit interface{}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		if op == "ListTables" {
+			limit = req["Limit"]
+		}
+		return stubResponse{body: map[string]interface{}{"TableNames": []string{}}}
+	})
+	defer server.Close()
+
+	db, err := sql.Open("godynamo", server.connStr(""))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	if err = db.PingContext(context.Background()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if server.numCalls("ListTables") != 1 || limit != float64(1) {
+		t.Fatalf("%s failed: expected one ListTables call with Limit=1, received %d call(s) with Limit=%v", testName, server.numCalls("ListTables"), limit)
+	}
+}
+
+func TestConn_Ping_error(t *testing.T) {
+	testName := "TestConn_Ping_error"
+	server := newStubDynamoDBServer(func(op string, _ map[string]interface{}) stubResponse {
+		return stubError(http.StatusBadRequest, "UnrecognizedClientException", "The security token included in the request is invalid.")
+	})
+	defer server.Close()
+
+	db, err := sql.Open("godynamo", server.connStr(""))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = db.Close() }()
+	var apiErr smithy.APIError
+	if err = db.PingContext(context.Background()); !errors.As(err, &apiErr) || apiErr.ErrorCode() != "UnrecognizedClientException" {
+		t.Fatalf("%s failed: expected UnrecognizedClientException but received %v", testName, err)
+	}
+}
+
+func TestConn_ResetSession(t *testing.T) {
+	testName := "TestConn_ResetSession"
+	conn := &Conn{}
+	if _, err := conn.BeginTx(context.Background(), driver.TxOptions{}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	conn.txStmtList = append(conn.txStmtList, &txStmt{})
+	if err := conn.ResetSession(context.Background()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if conn.tx != nil || conn.txMode != txNone || conn.txStmtList != nil {
+		t.Fatalf("%s failed: transaction state was not cleared", testName)
+	}
+	if _, err := conn.BeginTx(context.Background(), driver.TxOptions{}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+func TestConn_IsValid(t *testing.T) {
+	testName := "TestConn_IsValid"
+	testData := map[txMode]bool{txNone: true, txStarted: true, txCommitting: false, txRollingBack: false}
+	for mode, expected := range testData {
+		conn := &Conn{txMode: mode}
+		if conn.IsValid() != expected {
+			t.Fatalf("%s failed: expected IsValid=%#v for txMode %d", testName, expected, mode)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// _pagingStubServer returns a stub server that serves numPages pages of one item each for ExecuteStatement, taking
+// delay to serve each page.
+func _pagingStubServer(numPages int, delay time.Duration) *stubDynamoDBServer {
+	return newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		time.Sleep(delay)
+		page := 0
+		if token, ok := req["NextToken"].(string); ok {
+			_, _ = fmt.Sscanf(token, "page-%d", &page)
+		}
+		body := map[string]interface{}{
+			"Items": []interface{}{map[string]interface{}{"id": map[string]interface{}{"S": fmt.Sprintf("%d", page)}}},
+		}
+		if page+1 < numPages {
+			body["NextToken"] = fmt.Sprintf("page-%d", page+1)
+		}
+		return stubResponse{body: body}
+	})
+}
+
+func _countRows(db *sql.DB, ctx context.Context) (int, error) {
+	rows, err := db.QueryContext(ctx, `SELECT * FROM "tbltest"`)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	return count, rows.Err()
+}
+
+func TestConn_timeout_pagination(t *testing.T) {
+	testName := "TestConn_timeout_pagination"
+	server := _pagingStubServer(4, 80*time.Millisecond)
+	defer server.Close()
+
+	// the per-request timeout applies to each page, not to the whole result set
+	db, _ := sql.Open("godynamo", server.connStr("TimeoutMs=200;MaxAttempts=1"))
+	defer func() { _ = db.Close() }()
+	count, err := _countRows(db, context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if count != 4 {
+		t.Fatalf("%s failed: expected %d rows but received %d", testName, 4, count)
+	}
+}
+
+func TestConn_timeout_request(t *testing.T) {
+	testName := "TestConn_timeout_request"
+	server := _pagingStubServer(1, 300*time.Millisecond)
+	defer server.Close()
+
+	db, _ := sql.Open("godynamo", server.connStr("TimeoutMs=100;MaxAttempts=1"))
+	defer func() { _ = db.Close() }()
+	if _, err := _countRows(db, context.Background()); err == nil {
+		t.Fatalf("%s failed: expected timeout error", testName)
+	}
+}
+
+func TestConn_timeout_resultSet(t *testing.T) {
+	testName := "TestConn_timeout_resultSet"
+	server := _pagingStubServer(4, 80*time.Millisecond)
+	defer server.Close()
+
+	db, _ := sql.Open("godynamo", server.connStr("TimeoutMs=200;ResultSetTimeoutMs=150;MaxAttempts=1"))
+	defer func() { _ = db.Close() }()
+	count, err := _countRows(db, context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%s failed: expected context.DeadlineExceeded but received %v", testName, err)
+	}
+	if count >= 4 {
+		t.Fatalf("%s failed: expected fewer than %d rows but received %d", testName, 4, count)
+	}
+}
+
+func TestConn_timeout_callerContext(t *testing.T) {
+	testName := "TestConn_timeout_callerContext"
+	server := _pagingStubServer(4, 80*time.Millisecond)
+	defer server.Close()
+
+	db, _ := sql.Open("godynamo", server.connStr("TimeoutMs=1000;MaxAttempts=1"))
+	defer func() { _ = db.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	if _, err := _countRows(db, ctx); !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		t.Fatalf("%s failed: expected error from caller's context but received %v", testName, err)
+	}
+}
+
+func TestConn_timeout_commit(t *testing.T) {
+	testName := "TestConn_timeout_commit"
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		return stubResponse{body: map[string]interface{}{"Responses": []interface{}{map[string]interface{}{}}}}
+	})
+	defer server.Close()
+
+	connector, err := (&Driver{}).OpenConnector(server.connStr(""))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	conn, _ := connector.Connect(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := conn.(*Conn).BeginTx(ctx, driver.TxOptions{})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	stmt, err := conn.Prepare(`INSERT INTO "tbltest" VALUE {'id': ?}`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = stmt.(driver.StmtExecContext).ExecContext(ctx, []driver.NamedValue{{Ordinal: 1, Value: "1"}}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	cancel()
+	if err = tx.Commit(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("%s failed: expected context.Canceled but received %v", testName, err)
+	}
+	if n := server.numCalls("ExecuteTransaction"); n != 0 {
+		t.Fatalf("%s failed: expected no ExecuteTransaction call but received %d", testName, n)
+	}
+}
@@ -0,0 +1,64 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// Connector is AWS DynamoDB implementation of driver.Connector.
+//
+// A Connector creates one DynamoDB client which is shared by all connections it opens.
+//
+// @Available since v1.4.0
+type Connector struct {
+	driver  *Driver
+	config  Config
+	client  DynamoDBAPI
+	timeout time.Duration
+
+	keySchemas *keySchemaCache // key schemas of tables, shared by all connections
+}
+
+// NewConnector creates a new Connector from the supplied Config. The returned Connector can be used with sql.OpenDB.
+//
+// Example:
+//
+//	connector, err := godynamo.NewConnector(godynamo.Config{Region: "us-east-1", AccessKeyID: "...", SecretAccessKey: "..."})
+//	if err != nil {
+//		panic(err)
+//	}
+//	db := sql.OpenDB(connector)
+//
+// @Available since v1.4.0
+func NewConnector(cfg Config) (*Connector, error) {
+	return newConnector(&Driver{}, cfg)
+}
+
+func newConnector(d *Driver, cfg Config) (*Connector, error) {
+	client, err := cfg.newClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &Connector{driver: d, config: cfg, client: client, timeout: cfg.timeout(),
+		keySchemas: newKeySchemaCache()}, nil
+}
+
+// Connect implements driver.Connector/Connect.
+func (c *Connector) Connect(_ context.Context) (driver.Conn, error) {
+	return &Conn{client: c.client, timeout: c.timeout, resultSetTimeout: c.config.ResultSetTimeout,
+		numberMode: c.config.NumberMode, widenColumns: c.config.WidenColumns,
+		widenMaxItems: c.config.widenColumnsMaxItems(), pageTokenSecret: []byte(c.config.PageTokenSecret),
+		prefetch: c.config.Prefetch, denyScans: c.config.DenyScans, txConflictRetry: c.config.TxConflictRetry,
+		keySchemas: c.keySchemas}, nil
+}
+
+// Driver implements driver.Connector/Driver.
+func (c *Connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Config returns the Config the Connector was created with.
+func (c *Connector) Config() Config {
+	return c.config
+}
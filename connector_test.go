@@ -0,0 +1,77 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func TestNewConnector(t *testing.T) {
+	testName := "TestNewConnector"
+	connector, err := NewConnector(Config{Region: "us-east-1", AccessKeyID: "id", SecretAccessKey: "secret", Endpoint: "http://localhost:8000"})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if connector.timeout != 0 {
+		t.Fatalf("%s failed: expected no timeout but received %s", testName, connector.timeout)
+	}
+
+	conn1, err := connector.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Connect", err)
+	}
+	conn2, err := connector.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Connect", err)
+	}
+	if conn1.(*Conn).client != conn2.(*Conn).client {
+		t.Fatalf("%s failed: connections must share the same client", testName)
+	}
+
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+	if _, ok := db.Driver().(*Driver); !ok {
+		t.Fatalf("%s failed: expected *Driver but received %T", testName, db.Driver())
+	}
+}
+
+func TestNewConnector_AWSConfig(t *testing.T) {
+	testName := "TestNewConnector_AWSConfig"
+	if _, err := NewConnector(Config{AWSConfigID: "not-registered"}); err != ErrUnknownAWSConfigID {
+		t.Fatalf("%s failed: expected ErrUnknownAWSConfigID but received %v", testName, err)
+	}
+
+	connector, err := NewConnector(Config{AWSConfig: &aws.Config{Region: "us-west-2"}, Timeout: 3 * time.Second})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if region := connector.client.(*dynamodb.Client).Options().Region; region != "us-west-2" {
+		t.Fatalf("%s failed: expected region %s but received %s", testName, "us-west-2", region)
+	}
+	if connector.timeout != 3*time.Second {
+		t.Fatalf("%s failed: expected timeout %s but received %s", testName, 3*time.Second, connector.timeout)
+	}
+}
+
+func TestDriver_OpenConnector(t *testing.T) {
+	testName := "TestDriver_OpenConnector"
+	_clearAwsEnv(t)
+	d := &Driver{}
+	connector, err := d.OpenConnector("Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint=http://localhost:8000;TimeoutMs=1234")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	cfg := connector.(*Connector).Config()
+	expected := Config{Region: "us-east-1", AccessKeyID: "id", SecretAccessKey: "secret", Endpoint: "http://localhost:8000", Timeout: 1234 * time.Millisecond}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, cfg)
+	}
+	if connector.Driver() != d {
+		t.Fatalf("%s failed: connector must return the driver that created it", testName)
+	}
+}
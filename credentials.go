@@ -0,0 +1,85 @@
+package godynamo
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	// ErrRoleArnRequired is returned when creating a connector with WebIdentityTokenFile specified but not RoleArn.
+	//
+	// @Available since v1.4.0
+	ErrRoleArnRequired = errors.New("RoleArn is required when WebIdentityTokenFile is specified")
+)
+
+// credentialsProvider builds the aws.CredentialsProvider from the settings in the Config:
+//
+//   - If AccessKeyID is specified, static credentials (AccessKeyID, SecretAccessKey and SessionToken) are used.
+//   - Otherwise, credentials are resolved by the default AWS SDK credential chain (environment variables, shared
+//     config/credentials files with the optional Profile, web identity, container and EC2 instance roles).
+//   - If WebIdentityTokenFile is specified, the role RoleArn is assumed with the web identity token read from the file.
+//   - Otherwise, if RoleArn is specified, the role is assumed (with optional ExternalID) using the credentials above.
+//
+// Assumed-role credentials are cached and refreshed automatically before they expire. httpClient is used to call AWS
+// STS and other credential services.
+func (cfg Config) credentialsProvider(ctx context.Context, httpClient aws.HTTPClient) (aws.CredentialsProvider, error) {
+	if cfg.WebIdentityTokenFile != "" && cfg.RoleArn == "" {
+		return nil, ErrRoleArnRequired
+	}
+
+	var baseProvider aws.CredentialsProvider
+	if cfg.AccessKeyID != "" {
+		baseProvider = credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)
+	} else if cfg.WebIdentityTokenFile == "" {
+		optFns := []func(*config.LoadOptions) error{config.WithHTTPClient(httpClient)}
+		if cfg.Region != "" {
+			optFns = append(optFns, config.WithRegion(cfg.Region))
+		}
+		if cfg.Profile != "" {
+			optFns = append(optFns, config.WithSharedConfigProfile(cfg.Profile))
+		}
+		awsCfg, err := config.LoadDefaultConfig(ctx, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		baseProvider = awsCfg.Credentials
+	}
+	if cfg.RoleArn == "" {
+		return baseProvider, nil
+	}
+
+	stsOpts := sts.Options{
+		Region:      cfg.Region,
+		Credentials: baseProvider,
+		HTTPClient:  httpClient,
+	}
+	if cfg.STSEndpoint != "" {
+		stsOpts.BaseEndpoint = aws.String(cfg.STSEndpoint)
+		if strings.HasPrefix(cfg.STSEndpoint, "http://") {
+			stsOpts.EndpointOptions.DisableHTTPS = true
+		}
+	}
+	stsClient := sts.New(stsOpts)
+
+	if cfg.WebIdentityTokenFile != "" {
+		return aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(stsClient, cfg.RoleArn,
+			stscreds.IdentityTokenFile(cfg.WebIdentityTokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = cfg.RoleSessionName
+			})), nil
+	}
+	return aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, cfg.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+		if cfg.ExternalID != "" {
+			o.ExternalID = aws.String(cfg.ExternalID)
+		}
+		if cfg.RoleSessionName != "" {
+			o.RoleSessionName = cfg.RoleSessionName
+		}
+	})), nil
+}
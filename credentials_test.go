@@ -0,0 +1,141 @@
+package godynamo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// stubSTSServer is a local stand-in for AWS STS, serving AssumeRole and AssumeRoleWithWebIdentity.
+type stubSTSServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	requests []url.Values
+}
+
+func newStubSTSServer() *stubSTSServer {
+	s := &stubSTSServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		s.lock.Lock()
+		s.requests = append(s.requests, r.PostForm)
+		s.lock.Unlock()
+		action := r.PostForm.Get("Action")
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>ASIA-%[1]s</AccessKeyId>
+      <SecretAccessKey>secret-%[1]s</SecretAccessKey>
+      <SessionToken>token-%[1]s</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>%[2]s</Arn>
+      <AssumedRoleId>AROA:session</AssumedRoleId>
+    </AssumedRoleUser>
+  </%[1]sResult>
+  <ResponseMetadata><RequestId>request-id</RequestId></ResponseMetadata>
+</%[1]sResponse>`, action, r.PostForm.Get("RoleArn"))
+	}))
+	return s
+}
+
+func (s *stubSTSServer) lastRequest() url.Values {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+func _retrieveCredentials(t *testing.T, testName, connStr string) (string, string, string) {
+	connector, err := (&Driver{}).OpenConnector(connStr)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/OpenConnector", err)
+	}
+	creds, err := connector.(*Connector).client.(*dynamodb.Client).Options().Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName+"/Retrieve", err)
+	}
+	return creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken
+}
+
+func TestConfig_credentials_static(t *testing.T) {
+	testName := "TestConfig_credentials_static"
+	akid, secret, token := _retrieveCredentials(t, testName, "Region=us-east-1;AkId=id;Secret_Key=secret;SessionToken=token")
+	if akid != "id" || secret != "secret" || token != "token" {
+		t.Fatalf("%s failed: received unexpected credentials %s/%s/%s", testName, akid, secret, token)
+	}
+}
+
+func TestConfig_credentials_profile(t *testing.T) {
+	testName := "TestConfig_credentials_profile"
+	credsFile := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(credsFile, []byte("[default]\naws_access_key_id = default-id\naws_secret_access_key = default-secret\n\n"+
+		"[test]\naws_access_key_id = profile-id\naws_secret_access_key = profile-secret\n"), 0600); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credsFile)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+
+	akid, _, _ := _retrieveCredentials(t, testName, "Region=us-east-1;Profile=test")
+	if akid != "profile-id" {
+		t.Fatalf("%s failed: expected access key id %s but received %s", testName, "profile-id", akid)
+	}
+	akid, _, _ = _retrieveCredentials(t, testName, "Region=us-east-1")
+	if akid != "default-id" {
+		t.Fatalf("%s failed: expected access key id %s but received %s", testName, "default-id", akid)
+	}
+}
+
+func TestConfig_credentials_assumeRole(t *testing.T) {
+	testName := "TestConfig_credentials_assumeRole"
+	stsServer := newStubSTSServer()
+	defer stsServer.Close()
+
+	roleArn := "arn:aws:iam::123456789012:role/test"
+	akid, secret, token := _retrieveCredentials(t, testName, fmt.Sprintf("Region=us-east-1;AkId=id;Secret_Key=secret;RoleArn=%s;ExternalId=ext;RoleSessionName=session;StsEndpoint=%s", roleArn, stsServer.URL))
+	if akid != "ASIA-AssumeRole" || secret != "secret-AssumeRole" || token != "token-AssumeRole" {
+		t.Fatalf("%s failed: received unexpected credentials %s/%s/%s", testName, akid, secret, token)
+	}
+	req := stsServer.lastRequest()
+	if req.Get("RoleArn") != roleArn || req.Get("ExternalId") != "ext" || req.Get("RoleSessionName") != "session" {
+		t.Fatalf("%s failed: unexpected AssumeRole request %#v", testName, req)
+	}
+}
+
+func TestConfig_credentials_webIdentity(t *testing.T) {
+	testName := "TestConfig_credentials_webIdentity"
+	stsServer := newStubSTSServer()
+	defer stsServer.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("web-identity-token"), 0600); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	roleArn := "arn:aws:iam::123456789012:role/web"
+	akid, _, token := _retrieveCredentials(t, testName, fmt.Sprintf("Region=us-east-1;RoleArn=%s;WebIdentityTokenFile=%s;StsEndpoint=%s", roleArn, tokenFile, stsServer.URL))
+	if akid != "ASIA-AssumeRoleWithWebIdentity" || token != "token-AssumeRoleWithWebIdentity" {
+		t.Fatalf("%s failed: received unexpected credentials %s/%s", testName, akid, token)
+	}
+	if req := stsServer.lastRequest(); req.Get("WebIdentityToken") != "web-identity-token" || req.Get("RoleArn") != roleArn {
+		t.Fatalf("%s failed: unexpected AssumeRoleWithWebIdentity request %#v", testName, req)
+	}
+
+	if _, err := (&Driver{}).OpenConnector("Region=us-east-1;WebIdentityTokenFile=" + tokenFile); err != ErrRoleArnRequired {
+		t.Fatalf("%s failed: expected ErrRoleArnRequired but received %v", testName, err)
+	}
+}
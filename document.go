@@ -0,0 +1,210 @@
+package godynamo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// docParam is a placeholder of a document literal, holding the index of its parameter.
+type docParam int
+
+// docMap is a map of a document literal, whose values are document nodes.
+type docMap map[string]interface{}
+
+// docList is a list of a document literal, whose elements are document nodes.
+type docList []interface{}
+
+// docSet is a set (<<...>>) of a document literal, whose elements are document nodes.
+type docSet []interface{}
+
+var reDocToken = regexp.MustCompile(`^(?:\s+|'(?:[^']|'')*'|-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?|[A-Za-z_]\w*|"[^"]*"|<<|>>|[{}\[\]:,?*])`)
+
+// docParser is a parser of PartiQL document literals, e.g. {'id': ?, 'tags': <<'a', 'b'>>, 'items': [1, {'n': true}]}.
+// A document node is either a types.AttributeValue (literal), a docParam, a docMap, a docList or a docSet.
+type docParser struct {
+	tokens    []string
+	pos       int
+	numParams int
+}
+
+func newDocParser(input string) (*docParser, error) {
+	var tokens []string
+	for pos := 0; pos < len(input); {
+		token := reDocToken.FindString(input[pos:])
+		if token == "" {
+			return nil, fmt.Errorf("unexpected character %q", input[pos:pos+1])
+		}
+		pos += len(token)
+		if strings.TrimSpace(token) != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return &docParser{tokens: tokens}, nil
+}
+
+func (p *docParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// accept consumes the next token if it equals (case-insensitively) keyword.
+func (p *docParser) accept(keyword string) bool {
+	if strings.EqualFold(p.peek(), keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *docParser) expect(keyword string) error {
+	if !p.accept(keyword) {
+		return p.unexpected("expected " + keyword)
+	}
+	return nil
+}
+
+func (p *docParser) unexpected(detail string) error {
+	if p.pos >= len(p.tokens) {
+		return fmt.Errorf("%s, found end of statement", detail)
+	}
+	return fmt.Errorf("%s, found %q", detail, p.peek())
+}
+
+// elements consumes the comma-separated nodes up to the closing token.
+func (p *docParser) elements(closing string) ([]interface{}, error) {
+	elements := make([]interface{}, 0)
+	if p.accept(closing) {
+		return elements, nil
+	}
+	for {
+		node, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, node)
+		if !p.accept(",") {
+			return elements, p.expect(closing)
+		}
+	}
+}
+
+// value consumes a document node.
+func (p *docParser) value() (interface{}, error) {
+	token := p.peek()
+	switch {
+	case token == "?":
+		p.pos++
+		p.numParams++
+		return docParam(p.numParams - 1), nil
+	case strings.HasPrefix(token, "'"):
+		p.pos++
+		return &types.AttributeValueMemberS{Value: strings.ReplaceAll(token[1:len(token)-1], "''", "'")}, nil
+	case token != "" && (token[0] == '-' || token[0] >= '0' && token[0] <= '9'):
+		p.pos++
+		return &types.AttributeValueMemberN{Value: token}, nil
+	case strings.EqualFold(token, "TRUE") || strings.EqualFold(token, "FALSE"):
+		p.pos++
+		return &types.AttributeValueMemberBOOL{Value: strings.EqualFold(token, "TRUE")}, nil
+	case strings.EqualFold(token, "NULL"):
+		p.pos++
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case p.accept("["):
+		elements, err := p.elements("]")
+		return docList(elements), err
+	case p.accept("<<"):
+		elements, err := p.elements(">>")
+		return docSet(elements), err
+	case p.accept("{"):
+		m := docMap{}
+		if p.accept("}") {
+			return m, nil
+		}
+		for {
+			key := p.peek()
+			if !strings.HasPrefix(key, "'") && !strings.HasPrefix(key, `"`) {
+				return nil, p.unexpected("expected an attribute name")
+			}
+			p.pos++
+			key = strings.ReplaceAll(key[1:len(key)-1], "''", "'")
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			node, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			m[key] = node
+			if !p.accept(",") {
+				return m, p.expect("}")
+			}
+		}
+	}
+	return nil, p.unexpected("expected a value")
+}
+
+// bindDoc builds the attribute value of a document node, substituting placeholders with params.
+func bindDoc(node interface{}, params []types.AttributeValue) (types.AttributeValue, error) {
+	switch v := node.(type) {
+	case types.AttributeValue:
+		return v, nil
+	case docParam:
+		if int(v) >= len(params) {
+			return nil, fmt.Errorf("missing value of parameter %d-th", int(v)+1)
+		}
+		return params[v], nil
+	case docMap:
+		m := make(map[string]types.AttributeValue, len(v))
+		for key, child := range v {
+			av, err := bindDoc(child, params)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = av
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	case docList:
+		l := make([]types.AttributeValue, len(v))
+		for i, child := range v {
+			av, err := bindDoc(child, params)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: l}, nil
+	case docSet:
+		var ss, ns []string
+		var bs [][]byte
+		for _, child := range v {
+			av, err := bindDoc(child, params)
+			if err != nil {
+				return nil, err
+			}
+			switch e := av.(type) {
+			case *types.AttributeValueMemberS:
+				ss = append(ss, e.Value)
+			case *types.AttributeValueMemberN:
+				ns = append(ns, e.Value)
+			case *types.AttributeValueMemberB:
+				bs = append(bs, e.Value)
+			default:
+				return nil, fmt.Errorf("set elements must be strings, numbers or binaries, found %T", av)
+			}
+		}
+		switch {
+		case len(ss) == len(v) && len(ss) > 0:
+			return &types.AttributeValueMemberSS{Value: ss}, nil
+		case len(ns) == len(v) && len(ns) > 0:
+			return &types.AttributeValueMemberNS{Value: ns}, nil
+		case len(bs) == len(v) && len(bs) > 0:
+			return &types.AttributeValueMemberBS{Value: bs}, nil
+		}
+		return nil, fmt.Errorf("set elements must be non-empty and of the same type")
+	}
+	return nil, fmt.Errorf("unexpected document node %T", node)
+}
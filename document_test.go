@@ -0,0 +1,200 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestBindDoc(t *testing.T) {
+	testName := "TestBindDoc"
+	p, err := newDocParser(`{'id': ?, 'n': -1.5, 'ok': false, 'nil': NULL, 'tags': <<'a', ?>>, 'nums': <<1, 2>>, 'l': [?, {'x': 'it''s'}]}`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	node, err := p.value()
+	if err != nil || p.numParams != 3 {
+		t.Fatalf("%s failed: %d params, error %v", testName, p.numParams, err)
+	}
+	params := []types.AttributeValue{
+		&types.AttributeValueMemberS{Value: "id1"},
+		&types.AttributeValueMemberS{Value: "b"},
+		&types.AttributeValueMemberN{Value: "7"},
+	}
+	av, err := bindDoc(node, params)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+		"id":   &types.AttributeValueMemberS{Value: "id1"},
+		"n":    &types.AttributeValueMemberN{Value: "-1.5"},
+		"ok":   &types.AttributeValueMemberBOOL{Value: false},
+		"nil":  &types.AttributeValueMemberNULL{Value: true},
+		"tags": &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"nums": &types.AttributeValueMemberNS{Value: []string{"1", "2"}},
+		"l": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberN{Value: "7"},
+			&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"x": &types.AttributeValueMemberS{Value: "it's"}}},
+		}},
+	}}
+	if !reflect.DeepEqual(av, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, av)
+	}
+
+	for _, doc := range []string{`<<'a', 1>>`, `<<>>`, `<<true>>`} {
+		p, _ := newDocParser(doc)
+		node, err := p.value()
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if _, err = bindDoc(node, nil); err == nil {
+			t.Fatalf("%s failed: expected error for set %s", testName, doc)
+		}
+	}
+}
+
+// _putStubServer returns a stub server recording the PutItem and TransactWriteItems requests it receives. PutItem
+// returns the item stored under the same id, if any.
+func _putStubServer(requests *[]map[string]interface{}) *stubDynamoDBServer {
+	var lock sync.Mutex
+	stored := map[string]interface{}{}
+	return newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		lock.Lock()
+		defer lock.Unlock()
+		*requests = append(*requests, req)
+		switch op {
+		case "PutItem":
+			item := req["Item"].(map[string]interface{})
+			id := item["id"].(map[string]interface{})["S"].(string)
+			old := stored[id]
+			stored[id] = item
+			body := map[string]interface{}{"ConsumedCapacity": map[string]interface{}{"TableName": req["TableName"], "CapacityUnits": 1.0}}
+			if old != nil && req["ReturnValues"] == "ALL_OLD" {
+				body["Attributes"] = old
+			}
+			return stubResponse{body: body}
+		case "TransactWriteItems":
+			return stubResponse{}
+		}
+		return stubError(400, "ValidationException", "unexpected operation "+op)
+	})
+}
+
+func TestStmtUpsert(t *testing.T) {
+	testName := "TestStmtUpsert"
+	var requests []map[string]interface{}
+	server := _putStubServer(&requests)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	result, err := db.Exec(`UPSERT INTO "tbltest" VALUE {'id': ?, 'n': ?, 'tags': <<'a', 'b'>>}`, "1", 10)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 1 {
+		t.Fatalf("%s failed: expected 1 affected row but received %d (error %v)", testName, n, err)
+	}
+	expected := map[string]interface{}{
+		"TableName":              "tbltest",
+		"ReturnConsumedCapacity": "TOTAL",
+		"Item": map[string]interface{}{
+			"id":   map[string]interface{}{"S": "1"},
+			"n":    map[string]interface{}{"N": "10"},
+			"tags": map[string]interface{}{"SS": []interface{}{"a", "b"}},
+		},
+	}
+	if !reflect.DeepEqual(requests[0], expected) {
+		t.Fatalf("%s failed: expected request %#v but received %#v", testName, expected, requests[0])
+	}
+
+	rows, err := db.Query(`REPLACE INTO "tbltest" VALUE {'id': ?, 'n': 11} RETURNING ALL OLD *`, "1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	var items []map[string]interface{}
+	cols, _ := rows.Columns()
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		_ = rows.Scan(ptrs...)
+		item := map[string]interface{}{}
+		for i, col := range cols {
+			item[col] = values[i]
+		}
+		items = append(items, item)
+	}
+	_ = rows.Close()
+	if len(items) != 1 || items[0]["n"] != 10.0 {
+		t.Fatalf("%s failed: expected the replaced item but received %#v", testName, items)
+	}
+
+	rows, err = db.Query(`UPSERT INTO "tbltest" VALUE {'id': '2'}`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if rows.Next() {
+		t.Fatalf("%s failed: expected empty result set", testName)
+	}
+	_ = rows.Close()
+}
+
+func TestStmtUpsert_Tx(t *testing.T) {
+	testName := "TestStmtUpsert_Tx"
+	var requests []map[string]interface{}
+	server := _putStubServer(&requests)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	result1, err := tx.Exec(`UPSERT INTO "tbltest" VALUE {'id': ?}`, "1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Exec(`REPLACE INTO "tbl2" VALUE {'id': ?}`, "2"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Exec(`INSERT INTO "tbltest" VALUE {'id': ?}`, "3"); !errors.Is(err, ErrTxMixedStatements) {
+		t.Fatalf("%s failed: expected ErrTxMixedStatements but received %v", testName, err)
+	}
+	if _, err = tx.Query(`UPSERT INTO "tbltest" VALUE {'id': ?} RETURNING ALL OLD *`, "4"); err == nil {
+		t.Fatalf("%s failed: expected error for RETURNING in transaction", testName)
+	}
+	if _, err = tx.Query(`UPSERT INTO "tbltest" VALUE {'id': ?}`, "5"); !errors.Is(err, ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx but received %v", testName, err)
+	}
+	if _, err = result1.RowsAffected(); !errors.Is(err, ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx but received %v", testName, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n, err := result1.RowsAffected(); err != nil || n != 1 {
+		t.Fatalf("%s failed: expected 1 affected row but received %d (error %v)", testName, n, err)
+	}
+	if server.numCalls("TransactWriteItems") != 1 || server.numCalls("PutItem") != 0 {
+		t.Fatalf("%s failed: expected a single TransactWriteItems call", testName)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"Put": map[string]interface{}{"TableName": "tbltest", "Item": map[string]interface{}{"id": map[string]interface{}{"S": "1"}}}},
+		map[string]interface{}{"Put": map[string]interface{}{"TableName": "tbl2", "Item": map[string]interface{}{"id": map[string]interface{}{"S": "2"}}}},
+	}
+	if items := requests[0]["TransactItems"]; !reflect.DeepEqual(items, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, items)
+	}
+	if token, _ := requests[0]["ClientRequestToken"].(string); len(token) != 32 {
+		t.Fatalf("%s failed: expected a generated ClientRequestToken but received %#v", testName, requests[0]["ClientRequestToken"])
+	}
+}
@@ -1,18 +1,15 @@
 package godynamo
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"os"
 	"reflect"
-	"strings"
 	"sync"
-	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/aws/transport/http"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/btnguyen2k/consu/reddo"
 )
@@ -33,6 +30,8 @@ func init() {
 }
 
 // Driver is AWS DynamoDB implementation of driver.Driver.
+//
+// Since v1.4.0, Driver also implements driver.DriverContext.
 type Driver struct {
 }
 
@@ -61,19 +60,9 @@ func parseParamValue(params map[string]string, typ reflect.Type, validator func(
 	return defaultVal
 }
 
-func parseConnString(connStr string) map[string]string {
-	params := make(map[string]string)
-	parts := strings.Split(connStr, ";")
-	for _, part := range parts {
-		tokens := strings.SplitN(strings.TrimSpace(part), "=", 2)
-		key := strings.ToUpper(strings.TrimSpace(tokens[0]))
-		if len(tokens) >= 2 {
-			params[key] = strings.TrimSpace(tokens[1])
-		} else {
-			params[key] = ""
-		}
-	}
-	return params
+// parseConnString parses a connection string into a map of uppercase keys to values.
+func parseConnString(connStr string) (map[string]string, error) {
+	return parseDSNParams(connStr)
 }
 
 // Open implements driver.Driver/Open.
@@ -83,42 +72,32 @@ func parseConnString(connStr string) map[string]string {
 //	Region=<region>;AkId=<aws-key-id>;Secret_Key=<aws-secret-key>[;Endpoint=<dynamodb-endpoint>][;TimeoutMs=<timeout-in-milliseconds>]
 //
 // If not supplied, default value for TimeoutMs is 10 seconds.
+//
+// Since v1.4.0, the URL format "dynamodb://..." is also supported, see ParseDSN.
+//
+// Note: since v1.4.0, Open parses the connection string every time it is called. Use OpenConnector or NewConnector
+// (e.g. via sql.OpenDB) to share one DynamoDB client among connections.
 func (d *Driver) Open(connStr string) (driver.Conn, error) {
-	params := parseConnString(connStr)
-	timeoutMs := parseParamValue(params, reddo.TypeInt, func(val interface{}) bool {
-		return val.(int64) >= 0
-	}, int64(10000), []string{"TIMEOUTMS"}, nil).(int64)
-	region := parseParamValue(params, reddo.TypeString, nil, "", []string{"REGION"}, []string{"AWS_REGION"}).(string)
-	akid := parseParamValue(params, reddo.TypeString, nil, "", []string{"AKID"}, []string{"AWS_ACCESS_KEY_ID", "AWS_AKID"}).(string)
-	secretKey := parseParamValue(params, reddo.TypeString, nil, "", []string{"SECRET_KEY", "SECRETKEY"}, []string{"AWS_SECRET_KEY", "AWS_SECRET_ACCESS_KEY"}).(string)
-	opts := dynamodb.Options{
-		Credentials: credentials.NewStaticCredentialsProvider(akid, secretKey, ""),
-		HTTPClient:  http.NewBuildableClient().WithTimeout(time.Millisecond * time.Duration(timeoutMs)),
-		Region:      region,
-	}
-	endpoint := parseParamValue(params, reddo.TypeString, nil, "", []string{"ENDPOINT"}, []string{"AWS_DYNAMODB_ENDPOINT"}).(string)
-	if endpoint != "" {
-		//opts.EndpointResolver = dynamodb.EndpointResolverFromURL(endpoint)
-		opts.BaseEndpoint = aws.String(endpoint)
-		if strings.HasPrefix(endpoint, "http://") {
-			opts.EndpointOptions.DisableHTTPS = true
-		}
+	connector, err := d.OpenConnector(connStr)
+	if err != nil {
+		return nil, err
 	}
+	return connector.Connect(context.Background())
+}
 
-	var client *dynamodb.Client
-	if awsConfigID, ok := params[AWSConfigID]; ok {
-		awsConfigLock.RLock()
-		conf, ok := awsConfig[awsConfigID]
-		awsConfigLock.RUnlock()
-		if !ok {
-			return nil, ErrUnknownAWSConfigID
-		}
-		client = dynamodb.NewFromConfig(conf, mergeDynamoDBOptions(opts))
-	} else {
-		client = dynamodb.New(opts)
+// OpenConnector implements driver.DriverContext/OpenConnector.
+//
+// connStr is expected in the same format as Open. The returned Connector creates one DynamoDB client which is shared
+// by all connections it opens.
+//
+// @Available since v1.4.0
+func (d *Driver) OpenConnector(connStr string) (driver.Connector, error) {
+	cfg, err := ParseDSN(connStr)
+	var warning *DSNWarning
+	if err != nil && !errors.As(err, &warning) {
+		return nil, err
 	}
-
-	return &Conn{client: client, timeout: time.Duration(timeoutMs) * time.Millisecond}, nil
+	return newConnector(d, cfg)
 }
 
 // awsConfig is the AWS configuration to be used by the dynamodb client.
@@ -130,7 +109,7 @@ var (
 // RegisterAWSConfig registers aws.Config to be used by the dynamodb client.
 //
 // The following configurations do not apply even if they are set in aws.Config.
-//   - HTTPClient
+//   - HTTPClient: since v1.4.0, use RegisterHTTPClient instead.
 //
 // @Available since v1.3.0
 func RegisterAWSConfig(id string, conf aws.Config) {
@@ -163,5 +142,11 @@ func mergeDynamoDBOptions(providedOpts dynamodb.Options) func(*dynamodb.Options)
 			defaultOpts.BaseEndpoint = providedOpts.BaseEndpoint
 			defaultOpts.EndpointOptions = providedOpts.EndpointOptions
 		}
+
+		if providedOpts.Retryer != nil {
+			defaultOpts.Retryer = providedOpts.Retryer
+			// the retryer already enforces the configured max attempts, prevent the SDK from wrapping it
+			defaultOpts.RetryMaxAttempts = 0
+		}
 	}
 }
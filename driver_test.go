@@ -1,6 +1,7 @@
 package godynamo
 
 import (
+	"errors"
 	"os"
 	"reflect"
 	"testing"
@@ -106,7 +107,10 @@ func Test_parseConnString_parseParamValue(t *testing.T) {
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			params := parseConnString(testCase.connStr)
+			params, err := parseConnString(testCase.connStr)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
 			if !reflect.DeepEqual(params, testCase.expectedParams) {
 				t.Fatalf("%s failed: expected %#v received %#v", testName+"/"+testCase.name, testCase.expectedParams, params)
 			}
@@ -120,6 +124,15 @@ func Test_parseConnString_parseParamValue(t *testing.T) {
 	}
 }
 
+func Test_parseConnString_invalid(t *testing.T) {
+	testName := "Test_parseConnString_invalid"
+	for _, connStr := range []string{`Region="us-east-1`, `Region="us-east-1"x`, "dynamodb://us-east-1/path"} {
+		if params, err := parseConnString(connStr); !errors.Is(err, ErrInvalidDSN) || params != nil {
+			t.Fatalf("%s failed: expected ErrInvalidDSN for %s but received %#v (error %v)", testName, connStr, params, err)
+		}
+	}
+}
+
 func TestDriverMultipleConfigs(t *testing.T) {
 	// Test registering and using AWS config
 	configID := "test-config-1"
@@ -0,0 +1,376 @@
+package godynamo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrInvalidDSN is wrapped by all errors returned by ParseDSN.
+	//
+	// @Available since v1.4.0
+	ErrInvalidDSN = errors.New("invalid DSN")
+)
+
+// dsnScheme is the scheme of URL-style connection strings.
+const dsnScheme = "dynamodb"
+
+// dsnParamValidators lists the keys (uppercase) recognized in connection strings, each with a function validating
+// its value. Unknown keys are reported only in strict mode.
+var dsnParamValidators = map[string]func(string) error{
+	"REGION": nil, "AKID": nil, "SECRET_KEY": nil, "SECRETKEY": nil, "SESSION_TOKEN": nil, "SESSIONTOKEN": nil,
+	"PROFILE": nil, "ROLEARN": nil, "EXTERNALID": nil, "ROLESESSIONNAME": nil, "WEBIDENTITYTOKENFILE": nil,
+	AWSConfigID: nil,
+
+	"ENDPOINT": validateURL, "STSENDPOINT": validateURL, "STS_ENDPOINT": validateURL,
+
+	"TIMEOUTMS": validateNonNegativeInt, "RESULTSETTIMEOUTMS": validateNonNegativeInt,
+	"MAXATTEMPTS": validateNonNegativeInt, "MAXBACKOFFMS": validateNonNegativeInt,
+	"THROTTLEMAXATTEMPTS": validateNonNegativeInt, "THROTTLEMAXBACKOFFMS": validateNonNegativeInt,
+	"TXCONFLICTMAXATTEMPTS": validateNonNegativeInt, "TXCONFLICTMAXBACKOFFMS": validateNonNegativeInt,
+
+	"RETRYMODE": validateRetryMode, "RETRY_MODE": validateRetryMode,
+
+	"NUMBERMODE": validateNumberMode, "NUMBER_MODE": validateNumberMode, "WIDENCOLUMNS": validateBool,
+	"WIDENCOLUMNSMAXITEMS": validateNonNegativeInt,
+	"PAGETOKENSECRET":      nil, "PREFETCH": validateNonNegativeInt,
+	"DENYSCANS": validateBool,
+
+	"PROXYURL": validateProxyURL, "CABUNDLE": nil, "CLIENTCERT": nil, "CLIENTKEY": nil, "HTTPCLIENTID": nil,
+	"MAXIDLECONNS": validateNonNegativeInt, "MAXIDLECONNSPERHOST": validateNonNegativeInt,
+
+	"STRICT": validateBool,
+}
+
+// dsnDurationKeys maps keys accepting a Go duration (e.g. "Timeout=5s") to the corresponding keys in milliseconds.
+var dsnDurationKeys = map[string]string{
+	"TIMEOUT":              "TIMEOUTMS",
+	"RESULTSETTIMEOUT":     "RESULTSETTIMEOUTMS",
+	"MAXBACKOFF":           "MAXBACKOFFMS",
+	"THROTTLEMAXBACKOFF":   "THROTTLEMAXBACKOFFMS",
+	"TXCONFLICTMAXBACKOFF": "TXCONFLICTMAXBACKOFFMS",
+}
+
+func validateURL(val string) error {
+	u, err := url.Parse(val)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.New("expected an absolute http(s) URL")
+	}
+	return nil
+}
+
+func validateProxyURL(val string) error {
+	u, err := url.Parse(val)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" || u.Host == "" {
+		return errors.New("expected an absolute http(s) or socks5 URL")
+	}
+	return nil
+}
+
+func validateNonNegativeInt(val string) error {
+	v, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return errors.New("expected an integer")
+	}
+	if v < 0 {
+		return errors.New("expected a non-negative value")
+	}
+	return nil
+}
+
+func validateRetryMode(val string) error {
+	_, err := aws.ParseRetryMode(val)
+	return err
+}
+
+func validateNumberMode(val string) error {
+	_, err := ParseNumberMode(val)
+	return err
+}
+
+func validateBool(val string) error {
+	_, err := strconv.ParseBool(val)
+	return err
+}
+
+// ParseDSN parses a connection string into a Config. Two formats are supported:
+//
+//	Region=<region>;AkId=<aws-key-id>;Secret_Key=<aws-secret-key>[;Endpoint=<dynamodb-endpoint>][;TimeoutMs=<timeout-in-milliseconds>]
+//	dynamodb://[<aws-key-id>:<aws-secret-key>@]<region>/[?endpoint=<dynamodb-endpoint>][&timeout=<duration>]
+//
+// Keys are case-insensitive. In the first format, a value containing ';' can be enclosed in double quotes, with '"'
+// escaped as '""'. In the URL format, user info and values are percent-encoded.
+//
+// Settings not specified in the connection string are taken from environment variables where applicable (e.g.
+// AWS_REGION). Unknown keys and invalid values are ignored, unless Strict=true is specified, in which case ParseDSN
+// returns an error describing them. Otherwise, if values are invalid, ParseDSN returns the Config together with a
+// *DSNWarning describing them.
+//
+// @Available since v1.4.0
+func ParseDSN(dsn string) (Config, error) {
+	params, err := parseDSNParams(dsn)
+	if err != nil {
+		return Config{}, err
+	}
+	strict := false
+	if val, ok := params["STRICT"]; ok {
+		if strict, err = strconv.ParseBool(val); err != nil {
+			return Config{}, fmt.Errorf("%w: invalid value %q for key STRICT", ErrInvalidDSN, val)
+		}
+	}
+	errs := normalizeDSNDurations(params)
+	invalidErrs, unknownErrs := validateDSNParams(params)
+	errs = append(errs, invalidErrs...)
+	if strict {
+		if errs = append(errs, unknownErrs...); len(errs) > 0 {
+			return Config{}, errors.Join(errs...)
+		}
+	}
+	cfg := parseConfig(params)
+	if len(errs) > 0 {
+		return cfg, &DSNWarning{Errs: errs}
+	}
+	return cfg, nil
+}
+
+// DSNWarning is returned by ParseDSN, together with a usable Config, if keys of a connection string parsed without
+// Strict=true have invalid values. Values that cannot be parsed (e.g. RetryMode=foo or Timeout=abc) are replaced by
+// their defaults in the Config.
+//
+// Open and OpenConnector ignore warnings; specify Strict=true to make them fail instead.
+//
+// @Available since v1.4.0
+type DSNWarning struct {
+	// Errs describes the invalid values, each wrapping ErrInvalidDSN.
+	Errs []error
+}
+
+// Error implements error/Error.
+func (w *DSNWarning) Error() string {
+	return fmt.Sprintf("invalid values in non-strict mode: %s", errors.Join(w.Errs...))
+}
+
+// Unwrap returns the errors describing the invalid values.
+func (w *DSNWarning) Unwrap() []error {
+	return w.Errs
+}
+
+// parseDSNParams parses a connection string, in either format, into a map of uppercase keys to values.
+func parseDSNParams(dsn string) (map[string]string, error) {
+	dsn = strings.TrimSpace(dsn)
+	if len(dsn) > len(dsnScheme)+3 && strings.EqualFold(dsn[:len(dsnScheme)+3], dsnScheme+"://") {
+		return parseURLDSN(dsn)
+	}
+	return parseKeyValueDSN(dsn)
+}
+
+// parseURLDSN parses a connection string in the format dynamodb://[<aws-key-id>:<aws-secret-key>@]<region>/?<params>.
+func parseURLDSN(dsn string) (map[string]string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDSN, err)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("%w: unexpected path %q", ErrInvalidDSN, u.Path)
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDSN, err)
+	}
+	params := make(map[string]string)
+	for k, v := range query {
+		params[strings.ToUpper(strings.TrimSpace(k))] = strings.TrimSpace(v[len(v)-1])
+	}
+	if u.Host != "" {
+		params["REGION"] = u.Host
+	}
+	if u.User != nil {
+		params["AKID"] = u.User.Username()
+		if secret, ok := u.User.Password(); ok {
+			params["SECRET_KEY"] = secret
+		}
+	}
+	return params, nil
+}
+
+// parseKeyValueDSN parses a connection string in the format Key1=Value1;Key2="Value;2".
+func parseKeyValueDSN(dsn string) (map[string]string, error) {
+	params := make(map[string]string)
+	for len(dsn) > 0 {
+		end := strings.IndexAny(dsn, "=;")
+		if end < 0 || dsn[end] == ';' {
+			if end < 0 {
+				end = len(dsn)
+			}
+			params[strings.ToUpper(strings.TrimSpace(dsn[:end]))] = ""
+			dsn = dsn[min(end+1, len(dsn)):]
+			continue
+		}
+		key := strings.ToUpper(strings.TrimSpace(dsn[:end]))
+		dsn = strings.TrimLeft(dsn[end+1:], " \t")
+		if !strings.HasPrefix(dsn, `"`) {
+			end = strings.IndexByte(dsn, ';')
+			if end < 0 {
+				end = len(dsn)
+			}
+			params[key] = strings.TrimSpace(dsn[:end])
+			dsn = dsn[min(end+1, len(dsn)):]
+			continue
+		}
+
+		// quoted value, '"' is escaped as '""'
+		value := strings.Builder{}
+		i, closed := 1, false
+		for ; i < len(dsn); i++ {
+			if dsn[i] == '"' {
+				if i+1 < len(dsn) && dsn[i+1] == '"' {
+					value.WriteByte('"')
+					i++
+					continue
+				}
+				closed = true
+				break
+			}
+			value.WriteByte(dsn[i])
+		}
+		if !closed {
+			return nil, fmt.Errorf("%w: unterminated quoted value for key %q", ErrInvalidDSN, key)
+		}
+		rest := strings.TrimLeft(dsn[i+1:], " \t")
+		if rest != "" && rest[0] != ';' {
+			return nil, fmt.Errorf("%w: unexpected %q after quoted value for key %q", ErrInvalidDSN, rest, key)
+		}
+		params[key] = value.String()
+		dsn = strings.TrimPrefix(rest, ";")
+	}
+	return params, nil
+}
+
+// normalizeDSNDurations converts keys accepting a Go duration to the corresponding keys in milliseconds. Keys in
+// milliseconds take precedence if both are specified. Invalid durations are removed from params and reported.
+func normalizeDSNDurations(params map[string]string) []error {
+	var errs []error
+	for key, msKey := range dsnDurationKeys {
+		val, ok := params[key]
+		if !ok {
+			continue
+		}
+		delete(params, key)
+		d, err := time.ParseDuration(val)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("%w: invalid value %q for key %s: expected a non-negative duration such as 5s", ErrInvalidDSN, val, key))
+			continue
+		}
+		if _, ok := params[msKey]; !ok {
+			params[msKey] = strconv.FormatInt(d.Milliseconds(), 10)
+		}
+	}
+	return errs
+}
+
+// validateDSNParams reports invalid values and unknown keys in params, each sorted by key.
+func validateDSNParams(params map[string]string) (invalidErrs, unknownErrs []error) {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		validator, ok := dsnParamValidators[key]
+		if !ok {
+			unknownErrs = append(unknownErrs, fmt.Errorf("%w: unknown key %s", ErrInvalidDSN, key))
+			continue
+		}
+		if validator != nil {
+			if err := validator(params[key]); err != nil {
+				invalidErrs = append(invalidErrs, fmt.Errorf("%w: invalid value %q for key %s: %s", ErrInvalidDSN, params[key], key, err))
+			}
+		}
+	}
+	return invalidErrs, unknownErrs
+}
+
+// FormatDSN formats the Config as a URL-style connection string, which can be parsed back by ParseDSN.
+//
+// AWSConfig, HTTPClient and ClientFactory cannot be represented in a connection string and are ignored.
+//
+// @Available since v1.4.0
+func (cfg Config) FormatDSN() string {
+	u := url.URL{Scheme: dsnScheme, Host: cfg.Region, Path: "/"}
+	if cfg.AccessKeyID != "" {
+		u.User = url.UserPassword(cfg.AccessKeyID, cfg.SecretAccessKey)
+	}
+	query := url.Values{}
+	setString := func(key, val string) {
+		if val != "" {
+			query.Set(key, val)
+		}
+	}
+	setDuration := func(key string, val time.Duration) {
+		if val > 0 {
+			query.Set(key, val.String())
+		}
+	}
+	setInt := func(key string, val int) {
+		if val > 0 {
+			query.Set(key, strconv.Itoa(val))
+		}
+	}
+	setString("sessionToken", cfg.SessionToken)
+	setString("profile", cfg.Profile)
+	setString("roleArn", cfg.RoleArn)
+	setString("externalId", cfg.ExternalID)
+	setString("roleSessionName", cfg.RoleSessionName)
+	setString("webIdentityTokenFile", cfg.WebIdentityTokenFile)
+	setString("stsEndpoint", cfg.STSEndpoint)
+	setString("endpoint", cfg.Endpoint)
+	// a zero timeout means no timeout, while an absent timeout means DefaultTimeout
+	query.Set("timeout", cfg.timeout().String())
+	setDuration("resultSetTimeout", cfg.ResultSetTimeout)
+	setString("numberMode", string(cfg.NumberMode))
+	if cfg.WidenColumns {
+		query.Set("widenColumns", "true")
+	}
+	setInt("widenColumnsMaxItems", cfg.WidenColumnsMaxItems)
+	setString("pageTokenSecret", cfg.PageTokenSecret)
+	setInt("prefetch", cfg.Prefetch)
+	if cfg.DenyScans {
+		query.Set("denyScans", "true")
+	}
+	setString("retryMode", string(cfg.RetryMode))
+	setInt("maxAttempts", cfg.Retry.MaxAttempts)
+	setDuration("maxBackoff", cfg.Retry.MaxBackoff)
+	if cfg.ThrottleRetry != nil {
+		query.Set("throttleMaxAttempts", strconv.Itoa(cfg.ThrottleRetry.MaxAttempts))
+		setDuration("throttleMaxBackoff", cfg.ThrottleRetry.MaxBackoff)
+	}
+	if cfg.TxConflictRetry != nil {
+		query.Set("txConflictMaxAttempts", strconv.Itoa(cfg.TxConflictRetry.MaxAttempts))
+		setDuration("txConflictMaxBackoff", cfg.TxConflictRetry.MaxBackoff)
+	}
+	setString("proxyUrl", cfg.ProxyURL)
+	setString("caBundle", cfg.CABundle)
+	setString("clientCert", cfg.ClientCert)
+	setString("clientKey", cfg.ClientKey)
+	setInt("maxIdleConns", cfg.MaxIdleConns)
+	setInt("maxIdleConnsPerHost", cfg.MaxIdleConnsPerHost)
+	setString("httpClientId", cfg.HTTPClientID)
+	setString(AWSConfigID, cfg.AWSConfigID)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
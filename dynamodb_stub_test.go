@@ -0,0 +1,94 @@
+package godynamo
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// stubResponse is the response a stubDynamoDBServer returns for a call.
+type stubResponse struct {
+	status int         // HTTP status, 200 if zero
+	body   interface{} // marshalled to JSON
+}
+
+// stubError builds the stubResponse of an AWS DynamoDB error.
+func stubError(status int, code, message string) stubResponse {
+	return stubResponse{status: status, body: map[string]interface{}{
+		"__type":  "com.amazonaws.dynamodb.v20120810#" + code,
+		"message": message,
+	}}
+}
+
+// stubDynamoDBServer is a local stand-in for AWS DynamoDB. The handler receives the operation name (e.g.
+// "ExecuteStatement") and the JSON request, and returns the response to send back.
+type stubDynamoDBServer struct {
+	*httptest.Server
+	lock    sync.Mutex
+	handler func(op string, req map[string]interface{}) stubResponse
+	calls   []string
+}
+
+func newStubDynamoDBServer(handler func(op string, req map[string]interface{}) stubResponse) *stubDynamoDBServer {
+	s := newUnstartedStubDynamoDBServer(handler)
+	s.Start()
+	return s
+}
+
+// newUnstartedStubDynamoDBServer returns a stubDynamoDBServer that is not yet started, so that its TLS settings can
+// be changed before calling Start or StartTLS.
+func newUnstartedStubDynamoDBServer(handler func(op string, req map[string]interface{}) stubResponse) *stubDynamoDBServer {
+	s := &stubDynamoDBServer{handler: handler}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := r.Header.Get("X-Amz-Target")
+		op = op[strings.LastIndex(op, ".")+1:]
+		var req map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &req)
+
+		s.lock.Lock()
+		s.calls = append(s.calls, op)
+		s.lock.Unlock()
+
+		resp := s.handler(op, req)
+		if resp.status == 0 {
+			resp.status = http.StatusOK
+		}
+		if resp.body == nil {
+			resp.body = map[string]interface{}{}
+		}
+		js, _ := json.Marshal(resp.body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(crc32.ChecksumIEEE(js)), 10))
+		w.WriteHeader(resp.status)
+		_, _ = w.Write(js)
+	}))
+	return s
+}
+
+// numCalls returns the number of calls made to the operation op.
+func (s *stubDynamoDBServer) numCalls(op string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := 0
+	for _, call := range s.calls {
+		if call == op {
+			count++
+		}
+	}
+	return count
+}
+
+// connStr returns a connection string pointing to the stub server.
+func (s *stubDynamoDBServer) connStr(extra string) string {
+	connStr := "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint=" + s.URL
+	if extra != "" {
+		connStr += ";" + extra
+	}
+	return connStr
+}
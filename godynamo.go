@@ -2,7 +2,9 @@ package godynamo
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"math/big"
 	"reflect"
 	"strconv"
 
@@ -80,8 +82,22 @@ func ToAttributeValueUnsafe(value interface{}) types.AttributeValue {
 }
 
 // ToAttributeValue marshals a Go value to AWS AttributeValue.
+//
+// @Since v1.4.0 json.Number and *big.Float values are marshalled as numbers, keeping their precision.
+//
+// @Since v1.4.0 StringSet, NumberSet and BinarySet values are marshalled as sets (SS, NS and BS).
 func ToAttributeValue(value interface{}) (types.AttributeValue, error) {
 	switch v := value.(type) {
+	case StringSet, NumberSet, BinarySet:
+		av, _ := v.(driver.Valuer).Value()
+		return ToAttributeValue(av)
+	case json.Number:
+		return &types.AttributeValueMemberN{Value: v.String()}, nil
+	case *big.Float:
+		if v == nil {
+			return &types.AttributeValueMemberNULL{Value: true}, nil
+		}
+		return &types.AttributeValueMemberN{Value: v.Text('g', -1)}, nil
 	case types.AttributeValueMemberB:
 		return &v, nil
 	case types.AttributeValueMemberBOOL:
@@ -0,0 +1,155 @@
+package godynamo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// NumberMode specifies the Go type DynamoDB numbers (N attributes) are returned as in result sets. Number sets (NS
+// attributes) are returned as slices of that type, e.g. []float64 by default; they can also be scanned into a NumberSet.
+//
+// @Available since v1.4.0
+type NumberMode string
+
+const (
+	// NumberModeFloat64 returns numbers as float64 (default). Integers of more than 53 bits and decimals of high
+	// precision are not represented exactly.
+	NumberModeFloat64 NumberMode = "float64"
+
+	// NumberModeJSON returns numbers as json.Number, keeping the exact value returned by DynamoDB.
+	NumberModeJSON NumberMode = "json"
+
+	// NumberModeString returns numbers as string, keeping the exact value returned by DynamoDB.
+	NumberModeString NumberMode = "string"
+
+	// NumberModeInt64 returns integral numbers that fit into int64 as int64, and other numbers as float64.
+	NumberModeInt64 NumberMode = "int64"
+
+	// NumberModeBigFloat returns numbers as *big.Float, with enough precision for the 38 significant digits
+	// supported by DynamoDB.
+	NumberModeBigFloat NumberMode = "bigfloat"
+)
+
+// bigFloatPrec is the precision (in bits) of *big.Float values returned in NumberModeBigFloat, enough for 38
+// decimal digits.
+const bigFloatPrec = 128
+
+var (
+	// ErrInvalidNumberMode is returned when parsing an unsupported number mode.
+	//
+	// @Available since v1.4.0
+	ErrInvalidNumberMode = errors.New("invalid number mode")
+)
+
+// ParseNumberMode parses a number mode (case-insensitive). An empty string is parsed as NumberModeFloat64.
+//
+// @Available since v1.4.0
+func ParseNumberMode(s string) (NumberMode, error) {
+	switch mode := NumberMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return NumberModeFloat64, nil
+	case NumberModeFloat64, NumberModeJSON, NumberModeString, NumberModeInt64, NumberModeBigFloat:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidNumberMode, s)
+}
+
+// unmarshal converts an attribute value to a Go value, returning numbers and number sets according to the mode, and
+// string and binary sets as StringSet and BinarySet.
+func (m NumberMode) unmarshal(av types.AttributeValue) (interface{}, error) {
+	var value interface{}
+	err := attributevalue.UnmarshalWithOptions(av, &value, func(opts *attributevalue.DecoderOptions) {
+		opts.UseNumber = true
+	})
+	return m.convert(value), err
+}
+
+// convert converts, recursively, all attributevalue.Number values to the type of the mode, and string and binary sets
+// to StringSet and BinarySet.
+func (m NumberMode) convert(value interface{}) interface{} {
+	switch v := value.(type) {
+	case attributevalue.Number:
+		return m.number(string(v))
+	case []attributevalue.Number:
+		return m.numberSet(v)
+	case []string:
+		return StringSet(v)
+	case [][]byte:
+		return BinarySet(v)
+	case []interface{}:
+		for i, e := range v {
+			v[i] = m.convert(e)
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = m.convert(e)
+		}
+	}
+	return value
+}
+
+func (m NumberMode) number(n string) interface{} {
+	switch m {
+	case NumberModeJSON:
+		return json.Number(n)
+	case NumberModeString:
+		return n
+	case NumberModeInt64:
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return i
+		}
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	case NumberModeBigFloat:
+		f, _, _ := big.ParseFloat(n, 10, bigFloatPrec, big.ToNearestEven)
+		return f
+	}
+	f, _ := strconv.ParseFloat(n, 64)
+	return f
+}
+
+func (m NumberMode) numberSet(ns []attributevalue.Number) interface{} {
+	switch m {
+	case NumberModeJSON:
+		result := make([]json.Number, len(ns))
+		for i, n := range ns {
+			result[i] = json.Number(n)
+		}
+		return result
+	case NumberModeString:
+		result := make([]string, len(ns))
+		for i, n := range ns {
+			result[i] = string(n)
+		}
+		return result
+	case NumberModeInt64:
+		result := make([]int64, len(ns))
+		for i, n := range ns {
+			v, ok := m.number(string(n)).(int64)
+			if !ok {
+				// not all numbers are integral
+				return NumberModeFloat64.numberSet(ns)
+			}
+			result[i] = v
+		}
+		return result
+	case NumberModeBigFloat:
+		result := make([]*big.Float, len(ns))
+		for i, n := range ns {
+			result[i] = m.number(string(n)).(*big.Float)
+		}
+		return result
+	}
+	result := make([]float64, len(ns))
+	for i, n := range ns {
+		result[i], _ = strconv.ParseFloat(string(n), 64)
+	}
+	return result
+}
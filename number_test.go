@@ -0,0 +1,151 @@
+package godynamo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestParseNumberMode(t *testing.T) {
+	testName := "TestParseNumberMode"
+	testData := map[string]NumberMode{"": NumberModeFloat64, "Float64": NumberModeFloat64, "json": NumberModeJSON,
+		"STRING": NumberModeString, " int64 ": NumberModeInt64, "bigFloat": NumberModeBigFloat}
+	for input, expected := range testData {
+		if mode, err := ParseNumberMode(input); err != nil || mode != expected {
+			t.Fatalf("%s failed: expected %#v for %q but received %#v (error %v)", testName, expected, input, mode, err)
+		}
+	}
+	if _, err := ParseNumberMode("decimal"); !errors.Is(err, ErrInvalidNumberMode) {
+		t.Fatalf("%s failed: expected ErrInvalidNumberMode but received %v", testName, err)
+	}
+}
+
+func TestNumberMode_unmarshal(t *testing.T) {
+	testName := "TestNumberMode_unmarshal"
+	bigID := "9007199254740993" // 2^53+1, not representable as float64
+	amount := "12345678901234567890.123456789"
+	av := &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+		"id":     &types.AttributeValueMemberN{Value: bigID},
+		"amount": &types.AttributeValueMemberN{Value: amount},
+		"list":   &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberN{Value: "1"}}},
+		"set":    &types.AttributeValueMemberNS{Value: []string{"1", "2"}},
+	}}
+	bigAmount, _, _ := big.ParseFloat(amount, 10, bigFloatPrec, big.ToNearestEven)
+	testData := []struct {
+		mode     NumberMode
+		expected map[string]interface{}
+	}{
+		{mode: "", expected: map[string]interface{}{"id": float64(9007199254740992), "amount": 12345678901234567890.123456789,
+			"list": []interface{}{1.0}, "set": []float64{1, 2}}},
+		{mode: NumberModeJSON, expected: map[string]interface{}{"id": json.Number(bigID), "amount": json.Number(amount),
+			"list": []interface{}{json.Number("1")}, "set": []json.Number{"1", "2"}}},
+		{mode: NumberModeString, expected: map[string]interface{}{"id": bigID, "amount": amount,
+			"list": []interface{}{"1"}, "set": []string{"1", "2"}}},
+		{mode: NumberModeInt64, expected: map[string]interface{}{"id": int64(9007199254740993), "amount": 12345678901234567890.123456789,
+			"list": []interface{}{int64(1)}, "set": []int64{1, 2}}},
+		{mode: NumberModeBigFloat, expected: map[string]interface{}{"id": new(big.Float).SetPrec(bigFloatPrec).SetInt64(9007199254740993),
+			"amount": bigAmount, "list": []interface{}{new(big.Float).SetPrec(bigFloatPrec).SetInt64(1)},
+			"set": []*big.Float{new(big.Float).SetPrec(bigFloatPrec).SetInt64(1), new(big.Float).SetPrec(bigFloatPrec).SetInt64(2)}}},
+	}
+	for _, testCase := range testData {
+		t.Run(string(testCase.mode), func(t *testing.T) {
+			value, err := testCase.mode.unmarshal(av)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+string(testCase.mode), err)
+			}
+			if testCase.mode == NumberModeBigFloat {
+				// compare *big.Float values by their text representations
+				value, testCase.expected = _bigFloatsToText(value), _bigFloatsToText(testCase.expected).(map[string]interface{})
+			}
+			if !reflect.DeepEqual(value, testCase.expected) {
+				t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+string(testCase.mode), testCase.expected, value)
+			}
+		})
+	}
+}
+
+func _bigFloatsToText(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Float:
+		return v.Text('g', -1)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = _bigFloatsToText(e)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			result[k] = _bigFloatsToText(e)
+		}
+		return result
+	}
+	return value
+}
+
+func TestToAttributeValue_number(t *testing.T) {
+	testName := "TestToAttributeValue_number"
+	amount, _, _ := big.ParseFloat("12345678901234567890.123456789", 10, bigFloatPrec, big.ToNearestEven)
+	testData := []struct {
+		value    interface{}
+		expected string
+	}{
+		{value: json.Number("9007199254740993"), expected: "9007199254740993"},
+		{value: amount, expected: "1.2345678901234567890123456789e+19"},
+	}
+	for _, testCase := range testData {
+		av, err := ToAttributeValue(testCase.value)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if n, ok := av.(*types.AttributeValueMemberN); !ok || n.Value != testCase.expected {
+			t.Fatalf("%s failed: expected number %s but received %#v", testName, testCase.expected, av)
+		}
+	}
+}
+
+func TestResultResultSet_numberMode(t *testing.T) {
+	testName := "TestResultResultSet_numberMode"
+	server := newStubDynamoDBServer(func(op string, _ map[string]interface{}) stubResponse {
+		return stubResponse{body: map[string]interface{}{
+			"Items": []interface{}{map[string]interface{}{"id": map[string]interface{}{"N": "9007199254740993"}}},
+		}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("NumberMode=json"))
+	defer func() { _ = db.Close() }()
+
+	testData := []struct {
+		query    string
+		scanType reflect.Type
+		expected interface{}
+	}{
+		{query: `SELECT * FROM "tbltest"`, scanType: reflect.TypeOf(json.Number("")), expected: json.Number("9007199254740993")},
+		{query: `SELECT * FROM "tbltest" WITH number_mode=int64`, scanType: typeAny, expected: int64(9007199254740993)},
+		{query: `SELECT * FROM "tbltest" WITH number_mode=float64`, scanType: typeN, expected: float64(9007199254740992)},
+	}
+	for _, testCase := range testData {
+		rows, err := db.Query(testCase.query)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		colTypes, _ := rows.ColumnTypes()
+		if scanType := colTypes[0].ScanType(); scanType != testCase.scanType {
+			t.Fatalf("%s failed: expected scan type %s but received %s", testName, testCase.scanType, scanType)
+		}
+		var value interface{}
+		if !rows.Next() {
+			t.Fatalf("%s failed: expected one row (error %v)", testName, rows.Err())
+		}
+		if err = rows.Scan(&value); err != nil || !reflect.DeepEqual(value, testCase.expected) {
+			t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, testCase.expected, value, err)
+		}
+		_ = rows.Close()
+	}
+}
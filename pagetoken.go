@@ -0,0 +1,120 @@
+package godynamo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrInvalidPageToken is returned when a page token passed via "WITH PageToken=..." is malformed, or its
+	// signature does not match.
+	//
+	// @Available since v1.4.0
+	ErrInvalidPageToken = errors.New("invalid page token")
+)
+
+// pageToken is the position of a result set: the DynamoDB NextToken of the page being read (empty for the first
+// page), and the number of items of the page already read.
+type pageToken struct {
+	NextToken string `json:"t,omitempty"`
+	Offset    int    `json:"o,omitempty"`
+}
+
+// encode returns the opaque string form of the token, signed with HMAC-SHA256 if secret is not empty.
+func (t pageToken) encode(secret []byte) string {
+	js, _ := json.Marshal(t)
+	token := base64.RawURLEncoding.EncodeToString(js)
+	if len(secret) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(signPageToken(token, secret))
+	}
+	return token
+}
+
+func signPageToken(token string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+// decodePageToken parses a token returned by pageToken.encode, verifying its signature if secret is not empty.
+func decodePageToken(token string, secret []byte) (pageToken, error) {
+	var result pageToken
+	payload, signature, signed := strings.Cut(token, ".")
+	if len(secret) > 0 {
+		mac, err := base64.RawURLEncoding.DecodeString(signature)
+		if !signed || err != nil || !hmac.Equal(mac, signPageToken(payload, secret)) {
+			return result, ErrInvalidPageToken
+		}
+	} else if signed {
+		return result, ErrInvalidPageToken
+	}
+	js, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(js, &result) != nil || result.Offset < 0 {
+		return result, ErrInvalidPageToken
+	}
+	return result, nil
+}
+
+/*----------------------------------------------------------------------*/
+
+type resumeTokenCollectorKey struct{}
+
+// ResumeTokenCollector receives the resume token of result sets read with a context, see WithResumeTokenCollector.
+//
+// @Available since v1.4.0
+type ResumeTokenCollector struct {
+	lock  sync.Mutex
+	token string
+}
+
+// WithResumeTokenCollector returns a copy of ctx with a new ResumeTokenCollector attached. When a result set of a
+// SELECT statement executed with the returned context is closed, its resume token is reported to the collector.
+// The token can be passed to a later SELECT statement via "WITH PageToken=?" to continue reading from where the
+// result set was closed.
+//
+// Example:
+//
+//	ctx, collector := godynamo.WithResumeTokenCollector(context.Background())
+//	rows, err := db.QueryContext(ctx, `SELECT * FROM "session" LIMIT 10`)
+//	... // read and close rows
+//	token := collector.Token() // empty if there are no more rows
+//	...
+//	rows, err = db.QueryContext(ctx, `SELECT * FROM "session" LIMIT 10 WITH PageToken=?`, token)
+//
+// @Available since v1.4.0
+func WithResumeTokenCollector(ctx context.Context) (context.Context, *ResumeTokenCollector) {
+	collector := &ResumeTokenCollector{}
+	return context.WithValue(ctx, resumeTokenCollectorKey{}, collector), collector
+}
+
+// resumeTokenCollectorFromContext returns the ResumeTokenCollector attached to ctx, or nil if none.
+func resumeTokenCollectorFromContext(ctx context.Context) *ResumeTokenCollector {
+	if ctx == nil {
+		return nil
+	}
+	collector, _ := ctx.Value(resumeTokenCollectorKey{}).(*ResumeTokenCollector)
+	return collector
+}
+
+// set stores the token. It is safe to call set on a nil collector.
+func (c *ResumeTokenCollector) set(token string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.token = token
+}
+
+// Token returns the resume token of the last result set closed, or an empty string if it has been read to the end.
+func (c *ResumeTokenCollector) Token() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.token
+}
@@ -0,0 +1,172 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPageToken_encode(t *testing.T) {
+	testName := "TestPageToken_encode"
+	token := pageToken{NextToken: "next/token+==", Offset: 3}
+	for _, secret := range []string{"", "s3cret"} {
+		encoded := token.encode([]byte(secret))
+		if strings.ContainsAny(encoded, "=+/ ") {
+			t.Fatalf("%s failed: token %q is not URL-safe", testName, encoded)
+		}
+		decoded, err := decodePageToken(encoded, []byte(secret))
+		if err != nil || decoded != token {
+			t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, token, decoded, err)
+		}
+	}
+
+	signed := token.encode([]byte("s3cret"))
+	payload, _, _ := strings.Cut(signed, ".")
+	forged := pageToken{NextToken: "other"}.encode(nil)
+	testData := []struct {
+		name   string
+		token  string
+		secret string
+	}{
+		{name: "wrong_secret", token: signed, secret: "other"},
+		{name: "unsigned", token: payload, secret: "s3cret"},
+		{name: "forged_payload", token: forged + signed[len(payload):], secret: "s3cret"},
+		{name: "signed_without_secret", token: signed, secret: ""},
+		{name: "malformed", token: "!!", secret: ""},
+		{name: "not_json", token: "YWJj", secret: ""},
+	}
+	for _, testCase := range testData {
+		if _, err := decodePageToken(testCase.token, []byte(testCase.secret)); !errors.Is(err, ErrInvalidPageToken) {
+			t.Fatalf("%s failed: expected ErrInvalidPageToken but received %v", testName+"/"+testCase.name, err)
+		}
+	}
+}
+
+// _pageTokenStubServer returns a server that returns numPages pages of 2 items each (ids 0, 1, 2...), and records
+// the NextToken and Limit of each call.
+func _pageTokenStubServer(numPages int, calls *[]string) *stubDynamoDBServer {
+	var lock sync.Mutex
+	return newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		page := 0
+		token, _ := req["NextToken"].(string)
+		_, _ = fmt.Sscanf(token, "page-%d", &page)
+		lock.Lock()
+		*calls = append(*calls, fmt.Sprintf("%s/%v", token, req["Limit"]))
+		lock.Unlock()
+		body := map[string]interface{}{"Items": []interface{}{
+			map[string]interface{}{"id": map[string]interface{}{"N": fmt.Sprintf("%d", page*2)}},
+			map[string]interface{}{"id": map[string]interface{}{"N": fmt.Sprintf("%d", page*2+1)}},
+		}}
+		if page+1 < numPages {
+			body["NextToken"] = fmt.Sprintf("page-%d", page+1)
+		}
+		return stubResponse{body: body}
+	})
+}
+
+func _readIds(db *sql.DB, ctx context.Context, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var ids []int
+	for rows.Next() {
+		var id float64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, int(id))
+	}
+	return ids, rows.Err()
+}
+
+func TestStmtSelect_pageToken(t *testing.T) {
+	testName := "TestStmtSelect_pageToken"
+	var calls []string
+	server := _pageTokenStubServer(3, &calls)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("PageTokenSecret=s3cret"))
+	defer func() { _ = db.Close() }()
+
+	ctx, collector := WithResumeTokenCollector(context.Background())
+	query := `SELECT * FROM "tbltest" LIMIT 3 WITH PageToken=?`
+	testData := []struct {
+		ids   []int
+		calls []string
+		more  bool
+	}{
+		// stops in the middle of the 2nd page
+		{ids: []int{0, 1, 2}, calls: []string{"/3", "page-1/3"}, more: true},
+		// resumes from the 2nd page, skipping the item already read
+		{ids: []int{3, 4, 5}, calls: []string{"page-1/3", "page-2/3"}, more: false},
+	}
+	token := ""
+	for i, testCase := range testData {
+		calls = nil
+		ids, err := _readIds(db, ctx, query, token)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if !reflect.DeepEqual(ids, testCase.ids) || !reflect.DeepEqual(calls, testCase.calls) {
+			t.Fatalf("%s failed: expected %#v/%#v at round %d but received %#v/%#v", testName, testCase.ids, testCase.calls, i, ids, calls)
+		}
+		if token = collector.Token(); (token != "") != testCase.more {
+			t.Fatalf("%s failed: unexpected token %q at round %d", testName, token, i)
+		}
+	}
+
+	// page boundaries
+	calls = nil
+	ids, err := _readIds(db, ctx, `SELECT * FROM "tbltest" LIMIT 2`)
+	if err != nil || !reflect.DeepEqual(ids, []int{0, 1}) {
+		t.Fatalf("%s failed: unexpected %#v (error %v)", testName, ids, err)
+	}
+	if ids, err = _readIds(db, ctx, `SELECT * FROM "tbltest" WITH NextToken=`+collector.Token()); err != nil || !reflect.DeepEqual(ids, []int{2, 3, 4, 5}) {
+		t.Fatalf("%s failed: unexpected %#v (error %v)", testName, ids, err)
+	}
+
+	// tampered tokens are rejected
+	if _, err = _readIds(db, ctx, query, "e30"); !errors.Is(err, ErrInvalidPageToken) {
+		t.Fatalf("%s failed: expected ErrInvalidPageToken but received %v", testName, err)
+	}
+}
+
+func TestStmtSelect_pageSize(t *testing.T) {
+	testName := "TestStmtSelect_pageSize"
+	var calls []string
+	server := _pageTokenStubServer(1, &calls)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	testData := []struct {
+		query    string
+		args     []interface{}
+		expected string
+	}{
+		{query: `SELECT * FROM "tbltest" WHERE id>? WITH PageSize=?`, args: []interface{}{0, 5}, expected: "/5"},
+		{query: `SELECT * FROM "tbltest" LIMIT 1 WITH PageSize=10`, expected: "/10"},
+		{query: `SELECT * FROM "tbltest" LIMIT 1`, expected: "/1"},
+	}
+	for _, testCase := range testData {
+		calls = nil
+		if _, err := _readIds(db, context.Background(), testCase.query, testCase.args...); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if len(calls) != 1 || calls[0] != testCase.expected {
+			t.Fatalf("%s failed: expected call %q but received %#v", testName, testCase.expected, calls)
+		}
+	}
+
+	for _, query := range []string{`SELECT * FROM "tbltest" WITH PageSize=0`, `SELECT * FROM "tbltest" WITH ConsistentRead=?`} {
+		if _, err := db.Query(query, true); err == nil {
+			t.Fatalf("%s failed: expected error for %s", testName, query)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResultResultSet_prefetch(t *testing.T) {
+	testName := "TestResultResultSet_prefetch"
+	server := _pagingStubServer(5, 20*time.Millisecond)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("Prefetch=2"))
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.Query(`SELECT * FROM "tbltest"`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		t.Fatalf("%s failed: expected a row (error %v)", testName, rows.Err())
+	}
+
+	// pages are fetched ahead while the current page is being read, but no more than 2
+	time.Sleep(200 * time.Millisecond)
+	if n := server.numCalls("ExecuteStatement"); n != 3 {
+		t.Fatalf("%s failed: expected %d calls but received %d", testName, 3, n)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		_ = rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil || len(ids) != 4 || ids[0] != "1" || ids[3] != "4" {
+		t.Fatalf("%s failed: unexpected rows %#v (error %v)", testName, ids, err)
+	}
+	if n := server.numCalls("ExecuteStatement"); n != 5 {
+		t.Fatalf("%s failed: expected %d calls but received %d", testName, 5, n)
+	}
+}
+
+func TestResultResultSet_prefetch_limit(t *testing.T) {
+	testName := "TestResultResultSet_prefetch_limit"
+	server := _pagingStubServer(10, 0)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.Query(`SELECT * FROM "tbltest" LIMIT 3 WITH Prefetch=5`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	_ = rows.Close()
+	if count != 3 {
+		t.Fatalf("%s failed: expected %d rows but received %d", testName, 3, count)
+	}
+	// no page is fetched beyond the limit
+	if n := server.numCalls("ExecuteStatement"); n != 3 {
+		t.Fatalf("%s failed: expected %d calls but received %d", testName, 3, n)
+	}
+
+	if _, err = db.Query(`SELECT * FROM "tbltest" WITH Prefetch=-1`); err == nil {
+		t.Fatalf("%s failed: expected error for invalid Prefetch", testName)
+	}
+}
+
+func TestResultResultSet_prefetch_close(t *testing.T) {
+	testName := "TestResultResultSet_prefetch_close"
+	server := _pagingStubServer(10, 300*time.Millisecond)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("Prefetch=3"))
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.Query(`SELECT * FROM "tbltest"`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	rows.Next()
+	// closing stops the prefetch goroutine without waiting for the call in flight
+	start := time.Now()
+	if err = rows.Close(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("%s failed: Close took %s", testName, d)
+	}
+	n := server.numCalls("ExecuteStatement")
+	time.Sleep(400 * time.Millisecond)
+	if server.numCalls("ExecuteStatement") != n {
+		t.Fatalf("%s failed: pages are fetched after Close", testName)
+	}
+}
+
+func TestResultResultSet_prefetch_cancel(t *testing.T) {
+	testName := "TestResultResultSet_prefetch_cancel"
+	server := _pagingStubServer(10, 100*time.Millisecond)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("Prefetch=2;MaxAttempts=1"))
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := db.QueryContext(ctx, `SELECT * FROM "tbltest"`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = rows.Close() }()
+	rows.Next()
+	cancel()
+	for rows.Next() {
+	}
+	if err = rows.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("%s failed: expected context.Canceled but received %v", testName, err)
+	}
+}
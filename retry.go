@@ -0,0 +1,176 @@
+package godynamo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/btnguyen2k/consu/reddo"
+)
+
+// RetryPolicy configures how failed calls to DynamoDB are retried.
+//
+// @Available since v1.4.0
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one. If zero, the AWS SDK default is used.
+	MaxAttempts int
+
+	// MaxBackoff is the maximum delay between two attempts. If zero, the AWS SDK default is used.
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return retry.DefaultMaxAttempts
+	}
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) backoff() retry.BackoffDelayer {
+	if p.MaxBackoff <= 0 {
+		return retry.NewExponentialJitterBackoff(retry.DefaultMaxBackoff)
+	}
+	return retry.NewExponentialJitterBackoff(p.MaxBackoff)
+}
+
+// parseRetryPolicy parses the retry policy from the parameters <prefix>MaxAttempts and <prefix>MaxBackoffMs. The
+// returned boolean is true if either parameter is present.
+func parseRetryPolicy(params map[string]string, prefix string, maxAttemptsEkeys []string) (RetryPolicy, bool) {
+	_, hasMaxAttempts := params[prefix+"MAXATTEMPTS"]
+	_, hasMaxBackoff := params[prefix+"MAXBACKOFFMS"]
+	isNonNegative := func(val interface{}) bool {
+		return val.(int64) >= 0
+	}
+	maxAttempts := parseParamValue(params, reddo.TypeInt, isNonNegative, int64(0), []string{prefix + "MAXATTEMPTS"}, maxAttemptsEkeys).(int64)
+	maxBackoffMs := parseParamValue(params, reddo.TypeInt, isNonNegative, int64(0), []string{prefix + "MAXBACKOFFMS"}, nil).(int64)
+	return RetryPolicy{
+		MaxAttempts: int(maxAttempts),
+		MaxBackoff:  time.Duration(maxBackoffMs) * time.Millisecond,
+	}, hasMaxAttempts || hasMaxBackoff
+}
+
+// errorCodeTransactionConflict is the error code returned when a request conflicts with an ongoing transaction.
+const errorCodeTransactionConflict = "TransactionConflictException"
+
+// isThrottleError returns true if err is a throttling error, e.g. ProvisionedThroughputExceededException or
+// ThrottlingException.
+func isThrottleError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		_, ok := retry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]
+		return ok
+	}
+	return false
+}
+
+// isTxConflictError returns true if err is a TransactionConflictException, or a TransactionCanceledException caused
+// only by transaction conflicts.
+func isTxConflictError(err error) bool {
+	var txCanceledErr *types.TransactionCanceledException
+	if errors.As(err, &txCanceledErr) {
+		conflict := false
+		for _, reason := range txCanceledErr.CancellationReasons {
+			switch aws.ToString(reason.Code) {
+			case "TransactionConflict":
+				conflict = true
+			case "", "None":
+			default:
+				return false
+			}
+		}
+		return conflict
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == errorCodeTransactionConflict
+}
+
+// policyRetryer wraps an AWS SDK retryer and applies separate retry policies to throttling errors and
+// transaction conflicts.
+type policyRetryer struct {
+	aws.RetryerV2
+	policy, throttlePolicy, txConflictPolicy    RetryPolicy
+	backoff, throttleBackoff, txConflictBackoff retry.BackoffDelayer
+	retryTxConflict                             bool
+}
+
+// MaxAttempts implements aws.Retryer/MaxAttempts.
+//
+// The returned value is the highest number of attempts of all policies, the policy of each error class is enforced
+// by RetryDelay.
+func (r *policyRetryer) MaxAttempts() int {
+	maxAttempts := r.policy.maxAttempts()
+	if v := r.throttlePolicy.maxAttempts(); v > maxAttempts {
+		maxAttempts = v
+	}
+	if v := r.txConflictPolicy.maxAttempts(); r.retryTxConflict && v > maxAttempts {
+		maxAttempts = v
+	}
+	return maxAttempts
+}
+
+// IsErrorRetryable implements aws.Retryer/IsErrorRetryable.
+func (r *policyRetryer) IsErrorRetryable(err error) bool {
+	if r.retryTxConflict && isTxConflictError(err) {
+		return true
+	}
+	return r.RetryerV2.IsErrorRetryable(err)
+}
+
+// RetryDelay implements aws.Retryer/RetryDelay.
+func (r *policyRetryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	policy, backoff := r.policy, r.backoff
+	if isThrottleError(err) {
+		policy, backoff = r.throttlePolicy, r.throttleBackoff
+	} else if r.retryTxConflict && isTxConflictError(err) {
+		policy, backoff = r.txConflictPolicy, r.txConflictBackoff
+	}
+	if attempt >= policy.maxAttempts() {
+		return 0, &retry.MaxAttemptsError{Attempt: attempt, Err: err}
+	}
+	return backoff.BackoffDelay(attempt, err)
+}
+
+// retryer builds the retryer from the retry settings in the Config, or returns nil if none is configured.
+func (cfg Config) retryer() aws.Retryer {
+	if cfg.RetryMode == "" && cfg.Retry == (RetryPolicy{}) && cfg.ThrottleRetry == nil && cfg.TxConflictRetry == nil {
+		return nil
+	}
+	r := &policyRetryer{policy: cfg.Retry, throttlePolicy: cfg.Retry, retryTxConflict: cfg.TxConflictRetry != nil}
+	if cfg.ThrottleRetry != nil {
+		r.throttlePolicy = *cfg.ThrottleRetry
+	}
+	if cfg.TxConflictRetry != nil {
+		r.txConflictPolicy = *cfg.TxConflictRetry
+	}
+	r.backoff, r.throttleBackoff, r.txConflictBackoff = r.policy.backoff(), r.throttlePolicy.backoff(), r.txConflictPolicy.backoff()
+
+	standardOpts := func(o *retry.StandardOptions) {
+		o.MaxAttempts = r.MaxAttempts()
+		// attempts are limited per error class by the policies, the retry quota of the AWS SDK would cap them
+		// across all calls of the client
+		o.RateLimiter = ratelimit.None
+	}
+	if cfg.RetryMode == aws.RetryModeAdaptive {
+		r.RetryerV2 = retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+			o.StandardOptions = append(o.StandardOptions, standardOpts)
+		})
+	} else {
+		r.RetryerV2 = retry.NewStandard(standardOpts)
+	}
+	return r
+}
+
+// withoutTxConflictRetry disables the retries of transaction conflicts by the retryer built from the Config, for
+// calls retrying them with a different input.
+func withoutTxConflictRetry(o *dynamodb.Options) {
+	if r, ok := o.Retryer.(*policyRetryer); ok && r.retryTxConflict {
+		noTxConflictRetry := *r
+		noTxConflictRetry.retryTxConflict = false
+		o.Retryer = &noTxConflictRetry
+	}
+}
@@ -0,0 +1,202 @@
+package godynamo
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestConfig_parseRetry(t *testing.T) {
+	testName := "TestConfig_parseRetry"
+	testData := []struct {
+		name          string
+		connStr       string
+		mode          aws.RetryMode
+		retry         RetryPolicy
+		throttleRetry *RetryPolicy
+		conflictRetry *RetryPolicy
+	}{
+		{name: "none", connStr: "Region=us-east-1"},
+		{name: "standard", connStr: "Region=us-east-1;RetryMode=standard;MaxAttempts=5;MaxBackoffMs=2000", mode: aws.RetryModeStandard,
+			retry: RetryPolicy{MaxAttempts: 5, MaxBackoff: 2 * time.Second}},
+		{name: "adaptive", connStr: "Region=us-east-1;RetryMode=adaptive", mode: aws.RetryModeAdaptive},
+		{name: "invalid_mode", connStr: "Region=us-east-1;RetryMode=invalid;MaxAttempts=-1"},
+		{name: "throttle", connStr: "Region=us-east-1;ThrottleMaxAttempts=10",
+			throttleRetry: &RetryPolicy{MaxAttempts: 10}},
+		{name: "tx_conflict", connStr: "Region=us-east-1;TxConflictMaxAttempts=4;TxConflictMaxBackoffMs=100",
+			conflictRetry: &RetryPolicy{MaxAttempts: 4, MaxBackoff: 100 * time.Millisecond}},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			params, err := parseConnString(testCase.connStr)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			cfg := parseConfig(params)
+			if cfg.RetryMode != testCase.mode {
+				t.Fatalf("%s failed: expected retry mode %#v but received %#v", testName+"/"+testCase.name, testCase.mode, cfg.RetryMode)
+			}
+			if cfg.Retry != testCase.retry {
+				t.Fatalf("%s failed: expected retry policy %#v but received %#v", testName+"/"+testCase.name, testCase.retry, cfg.Retry)
+			}
+			if !reflect.DeepEqual(cfg.ThrottleRetry, testCase.throttleRetry) {
+				t.Fatalf("%s failed: expected throttle retry policy %#v but received %#v", testName+"/"+testCase.name, testCase.throttleRetry, cfg.ThrottleRetry)
+			}
+			if !reflect.DeepEqual(cfg.TxConflictRetry, testCase.conflictRetry) {
+				t.Fatalf("%s failed: expected tx-conflict retry policy %#v but received %#v", testName+"/"+testCase.name, testCase.conflictRetry, cfg.TxConflictRetry)
+			}
+		})
+	}
+}
+
+// _failingStubServer returns a stub server that fails the first numFailures ExecuteStatement calls with errCode.
+func _failingStubServer(numFailures int, errCode string) *stubDynamoDBServer {
+	lock := sync.Mutex{}
+	count := 0
+	return newStubDynamoDBServer(func(op string, _ map[string]interface{}) stubResponse {
+		lock.Lock()
+		defer lock.Unlock()
+		if op == "ExecuteStatement" && count < numFailures {
+			count++
+			return stubError(http.StatusBadRequest, errCode, "injected error")
+		}
+		return stubResponse{body: map[string]interface{}{"Items": []interface{}{}}}
+	})
+}
+
+func _selectViaStub(connStr string) error {
+	db, err := sql.Open("godynamo", connStr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	rows, err := db.Query(`SELECT * FROM "tbltest"`)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+func TestRetry_throttle(t *testing.T) {
+	testName := "TestRetry_throttle"
+	testData := []struct {
+		name        string
+		errCode     string
+		numFailures int
+		extra       string
+		expectedErr bool
+		numCalls    int
+	}{
+		{name: "default_policy_exhausted", errCode: "ProvisionedThroughputExceededException", extra: "MaxAttempts=3;MaxBackoffMs=1", expectedErr: true, numCalls: 3},
+		{name: "throttle_policy", errCode: "ProvisionedThroughputExceededException", extra: "MaxAttempts=1;ThrottleMaxAttempts=5;ThrottleMaxBackoffMs=1", numCalls: 5},
+		{name: "throttle_policy_exhausted", errCode: "ThrottlingException", extra: "MaxAttempts=10;MaxBackoffMs=1;ThrottleMaxAttempts=2;ThrottleMaxBackoffMs=1", expectedErr: true, numCalls: 2},
+		{name: "adaptive", errCode: "ThrottlingException", extra: "RetryMode=adaptive;ThrottleMaxAttempts=2;ThrottleMaxBackoffMs=1", expectedErr: true, numCalls: 2},
+		// the default retry quota of the AWS SDK allows 100 retries
+		{name: "beyond_retry_quota", errCode: "ThrottlingException", numFailures: 120, extra: "ThrottleMaxAttempts=150;ThrottleMaxBackoffMs=1", numCalls: 121},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			numFailures := testCase.numFailures
+			if numFailures == 0 {
+				numFailures = 4
+			}
+			server := _failingStubServer(numFailures, testCase.errCode)
+			defer server.Close()
+			err := _selectViaStub(server.connStr(testCase.extra))
+			if testCase.expectedErr && err == nil {
+				t.Fatalf("%s failed: expected error", testName+"/"+testCase.name)
+			}
+			if !testCase.expectedErr && err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if n := server.numCalls("ExecuteStatement"); n != testCase.numCalls {
+				t.Fatalf("%s failed: expected %d calls but received %d", testName+"/"+testCase.name, testCase.numCalls, n)
+			}
+		})
+	}
+}
+
+func TestRetry_txConflict(t *testing.T) {
+	testName := "TestRetry_txConflict"
+	testData := []struct {
+		name        string
+		extra       string
+		expectedErr bool
+		numCalls    int
+	}{
+		{name: "not_retried", extra: "MaxAttempts=5;MaxBackoffMs=1", expectedErr: true, numCalls: 1},
+		{name: "retried", extra: "TxConflictMaxAttempts=3;TxConflictMaxBackoffMs=1", numCalls: 3},
+		{name: "exhausted", extra: "MaxAttempts=5;MaxBackoffMs=1;TxConflictMaxAttempts=2;TxConflictMaxBackoffMs=1", expectedErr: true, numCalls: 2},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := _failingStubServer(2, errorCodeTransactionConflict)
+			defer server.Close()
+			err := _selectViaStub(server.connStr(testCase.extra))
+			if testCase.expectedErr && err == nil {
+				t.Fatalf("%s failed: expected error", testName+"/"+testCase.name)
+			}
+			if !testCase.expectedErr && err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if n := server.numCalls("ExecuteStatement"); n != testCase.numCalls {
+				t.Fatalf("%s failed: expected %d calls but received %d", testName+"/"+testCase.name, testCase.numCalls, n)
+			}
+		})
+	}
+}
+
+func TestRetry_AWSConfig(t *testing.T) {
+	testName := "TestRetry_AWSConfig"
+	server := _failingStubServer(2, "ThrottlingException")
+	defer server.Close()
+
+	// the retry policy of the Config must take precedence over the max attempts of the aws.Config
+	connector, err := NewConnector(Config{
+		Endpoint:      server.URL,
+		AWSConfig:     &aws.Config{Region: "us-east-1", Credentials: aws.AnonymousCredentials{}, RetryMaxAttempts: 1},
+		ThrottleRetry: &RetryPolicy{MaxAttempts: 3, MaxBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+	rows, err := db.Query(`SELECT * FROM "tbltest"`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	_ = rows.Close()
+	if n := server.numCalls("ExecuteStatement"); n != 3 {
+		t.Fatalf("%s failed: expected %d calls but received %d", testName, 3, n)
+	}
+}
+
+func TestIsTxConflictError(t *testing.T) {
+	testName := "TestIsTxConflictError"
+	testData := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "generic", err: errors.New("dummy"), expected: false},
+		{name: "conflict", err: &types.TransactionConflictException{}, expected: true},
+		{name: "canceled_conflict", err: &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("None")}, {Code: aws.String("TransactionConflict")}}}, expected: true},
+		{name: "canceled_condition", err: &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("TransactionConflict")}}}, expected: false},
+		{name: "throttle", err: &types.ProvisionedThroughputExceededException{}, expected: false},
+	}
+	for _, testCase := range testData {
+		if isTxConflictError(testCase.err) != testCase.expected {
+			t.Fatalf("%s failed: expected %#v for %s", testName+"/"+testCase.name, testCase.expected, testCase.name)
+		}
+	}
+}
@@ -0,0 +1,725 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	// ErrUnsupportedScan is returned when a SELECT statement with "WITH Segments=..." can not be translated to a
+	// DynamoDB Scan request.
+	//
+	// @Available since v1.4.0
+	ErrUnsupportedScan = errors.New("statement is not supported by segmented scan")
+)
+
+// maxScanSegments is the maximum number of segments DynamoDB accepts in a Scan request.
+const maxScanSegments = 1000000
+
+// maxScanWorkers is the maximum number of segments of a parallel Scan request fetched concurrently.
+const maxScanWorkers = 16
+
+func parseSegments(val string) (int, error) {
+	segments, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || segments <= 0 || segments > maxScanSegments {
+		return 0, fmt.Errorf("invalid Segments value: %s", val)
+	}
+	return segments, nil
+}
+
+// scanOperand is a value of a filter expression: either a literal, or the index of a placeholder parameter.
+type scanOperand struct {
+	param int // index of the placeholder parameter, -1 if the value is a literal
+	value types.AttributeValue
+}
+
+// scanPlan is the translation of a statement to a Scan request, or to a Query request if keyCondition is not empty.
+type scanPlan struct {
+	segments     int
+	tableName    string
+	indexName    string
+	keyCondition string
+	descending   bool
+	projection   string
+	columns      []string // projected attributes, in order
+	filter       string
+	names        map[string]string // expression attribute names, alias -> name
+	values       []scanOperand     // expression attribute values, in order of :v0, :v1...
+	aliases      map[string]string // name -> alias, to reuse aliases
+	numParams    int
+}
+
+var reScanToken = regexp.MustCompile(`^(?:\s+|"[^"]*"|'(?:[^']|'')*'|-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?|[A-Za-z_]\w*|<=|>=|<>|!=|[=<>?(),.*\[\]])`)
+
+// newScanParser returns a parser of query, or an error built by errFn if query has unexpected characters.
+func newScanParser(query string, errFn func(detail string) error) (*scanParser, error) {
+	var tokens []string
+	for pos := 0; pos < len(query); {
+		token := reScanToken.FindString(query[pos:])
+		if token == "" {
+			return nil, errFn(fmt.Sprintf("unexpected character %q", query[pos:pos+1]))
+		}
+		pos += len(token)
+		if strings.TrimSpace(token) != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	plan := &scanPlan{segments: 1, names: map[string]string{}, aliases: map[string]string{}}
+	return &scanParser{tokens: tokens, plan: plan, errFn: errFn}, nil
+}
+
+// scanParser is a recursive-descent parser of the subset of SELECT statements supported by segmented scans:
+//
+//	SELECT * | attr[, attr...] FROM table[.index] [WHERE condition]
+//
+// where condition combines, with AND, OR, NOT and parentheses, comparisons (=, <>, !=, <, <=, >, >=), BETWEEN, IN,
+// IS [NOT] MISSING, and the functions begins_with, contains, attribute_type, EXISTS and MISSING. Operands are
+// attribute paths, placeholders, strings, numbers, booleans and NULL.
+type scanParser struct {
+	tokens []string
+	pos    int
+	plan   *scanPlan
+	errFn  func(detail string) error // builds parsing errors
+}
+
+func (p *scanParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// accept consumes the next token if it equals (case-insensitively) one of the keywords.
+func (p *scanParser) accept(keywords ...string) bool {
+	for _, keyword := range keywords {
+		if strings.EqualFold(p.peek(), keyword) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *scanParser) expect(keyword string) error {
+	if !p.accept(keyword) {
+		return p.unexpected("expected " + keyword)
+	}
+	return nil
+}
+
+func (p *scanParser) unexpected(detail string) error {
+	if p.pos >= len(p.tokens) {
+		return p.errFn(detail + ", found end of statement")
+	}
+	return p.errFn(fmt.Sprintf("%s, found %q", detail, p.peek()))
+}
+
+var scanReservedWords = map[string]bool{"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true,
+	"NOT": true, "BETWEEN": true, "IN": true, "IS": true, "MISSING": true, "TRUE": true, "FALSE": true, "NULL": true}
+
+// name consumes an attribute, table or index name: an identifier or a double-quoted name.
+func (p *scanParser) name() (string, error) {
+	token := p.peek()
+	switch {
+	case strings.HasPrefix(token, `"`):
+		p.pos++
+		return token[1 : len(token)-1], nil
+	case token != "" && (token[0] == '_' || token[0] >= 'A' && token[0] <= 'Z' || token[0] >= 'a' && token[0] <= 'z') && !scanReservedWords[strings.ToUpper(token)]:
+		p.pos++
+		return token, nil
+	}
+	return "", p.unexpected("expected a name")
+}
+
+// alias returns the expression attribute name of an attribute.
+func (p *scanParser) alias(name string) string {
+	if alias, ok := p.plan.aliases[name]; ok {
+		return alias
+	}
+	alias := "#n" + strconv.Itoa(len(p.plan.names))
+	p.plan.aliases[name] = alias
+	p.plan.names[alias] = name
+	return alias
+}
+
+// path consumes an attribute path (e.g. a.b) and returns its expression form.
+func (p *scanParser) path() (string, error) {
+	var parts []string
+	for {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, p.alias(name))
+		if !p.accept(".") {
+			return strings.Join(parts, "."), nil
+		}
+	}
+}
+
+func (p *scanParser) addValue(operand scanOperand) string {
+	p.plan.values = append(p.plan.values, operand)
+	return ":v" + strconv.Itoa(len(p.plan.values)-1)
+}
+
+// operand consumes a value or an attribute path and returns its expression form.
+func (p *scanParser) operand() (string, error) {
+	token := p.peek()
+	switch {
+	case token == "?":
+		p.pos++
+		p.plan.numParams++
+		return p.addValue(scanOperand{param: p.plan.numParams - 1}), nil
+	case strings.HasPrefix(token, "'"):
+		p.pos++
+		value := strings.ReplaceAll(token[1:len(token)-1], "''", "'")
+		return p.addValue(scanOperand{param: -1, value: &types.AttributeValueMemberS{Value: value}}), nil
+	case token != "" && (token[0] == '-' || token[0] >= '0' && token[0] <= '9'):
+		p.pos++
+		return p.addValue(scanOperand{param: -1, value: &types.AttributeValueMemberN{Value: token}}), nil
+	case strings.EqualFold(token, "TRUE") || strings.EqualFold(token, "FALSE"):
+		p.pos++
+		return p.addValue(scanOperand{param: -1, value: &types.AttributeValueMemberBOOL{Value: strings.EqualFold(token, "TRUE")}}), nil
+	case strings.EqualFold(token, "NULL"):
+		p.pos++
+		return p.addValue(scanOperand{param: -1, value: &types.AttributeValueMemberNULL{Value: true}}), nil
+	}
+	return p.path()
+}
+
+func (p *scanParser) or() (string, error) {
+	expr, err := p.and()
+	for err == nil && p.accept("OR") {
+		var right string
+		right, err = p.and()
+		expr += " OR " + right
+	}
+	return expr, err
+}
+
+func (p *scanParser) and() (string, error) {
+	expr, err := p.not()
+	for err == nil && p.accept("AND") {
+		var right string
+		right, err = p.not()
+		expr += " AND " + right
+	}
+	return expr, err
+}
+
+func (p *scanParser) not() (string, error) {
+	if p.accept("NOT") {
+		expr, err := p.not()
+		return "NOT " + expr, err
+	}
+	return p.predicate()
+}
+
+// scanFunctions maps the functions supported in conditions to their DynamoDB names and number of arguments.
+var scanFunctions = map[string]struct {
+	name    string
+	numArgs int
+}{
+	"BEGINS_WITH":    {"begins_with", 2},
+	"CONTAINS":       {"contains", 2},
+	"ATTRIBUTE_TYPE": {"attribute_type", 2},
+	"EXISTS":         {"attribute_exists", 1},
+	"MISSING":        {"attribute_not_exists", 1},
+}
+
+var scanComparators = map[string]string{"=": "=", "<>": "<>", "!=": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+func (p *scanParser) predicate() (string, error) {
+	if p.accept("(") {
+		expr, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		return "(" + expr + ")", p.expect(")")
+	}
+	if fn, ok := scanFunctions[strings.ToUpper(p.peek())]; ok && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "(" {
+		p.pos += 2
+		args := make([]string, fn.numArgs)
+		for i := range args {
+			if i > 0 {
+				if err := p.expect(","); err != nil {
+					return "", err
+				}
+			}
+			var err error
+			if i == 0 {
+				args[i], err = p.path()
+			} else {
+				args[i], err = p.operand()
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		return fn.name + "(" + strings.Join(args, ", ") + ")", p.expect(")")
+	}
+
+	left, err := p.path()
+	if err != nil {
+		return "", err
+	}
+	if op, ok := scanComparators[p.peek()]; ok {
+		p.pos++
+		right, err := p.operand()
+		return left + " " + op + " " + right, err
+	}
+	switch {
+	case p.accept("BETWEEN"):
+		low, err := p.operand()
+		if err != nil {
+			return "", err
+		}
+		if err = p.expect("AND"); err != nil {
+			return "", err
+		}
+		high, err := p.operand()
+		return left + " BETWEEN " + low + " AND " + high, err
+	case p.accept("IN"):
+		closing := ")"
+		if p.accept("[") {
+			closing = "]"
+		} else if err = p.expect("("); err != nil {
+			return "", err
+		}
+		var items []string
+		for {
+			item, err := p.operand()
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+		return left + " IN (" + strings.Join(items, ", ") + ")", p.expect(closing)
+	case p.accept("IS"):
+		fn := "attribute_not_exists"
+		if p.accept("NOT") {
+			fn = "attribute_exists"
+		}
+		return fn + "(" + left + ")", p.expect("MISSING")
+	}
+	return "", p.unexpected("expected a comparison operator")
+}
+
+// projection consumes a list of attribute names.
+func (p *scanParser) projection() error {
+	var projection []string
+	for {
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		p.plan.columns = append(p.plan.columns, name)
+		projection = append(projection, p.alias(name))
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.plan.projection = strings.Join(projection, ", ")
+	return nil
+}
+
+// table consumes a table name, optionally followed by an index name.
+func (p *scanParser) table() (err error) {
+	if p.plan.tableName, err = p.name(); err != nil {
+		return err
+	}
+	if p.accept(".") {
+		p.plan.indexName, err = p.name()
+	}
+	return err
+}
+
+// keyCondition consumes a key condition: the partition key equals a value, optionally AND a condition on the sort
+// key (comparison, BETWEEN, BEGINS_WITH or begins_with function).
+func (p *scanParser) keyCondition() (string, error) {
+	pk, err := p.path()
+	if err != nil {
+		return "", err
+	}
+	if err = p.expect("="); err != nil {
+		return "", err
+	}
+	value, err := p.operand()
+	if err != nil {
+		return "", err
+	}
+	expr := pk + " = " + value
+	if !p.accept("AND") {
+		return expr, nil
+	}
+	if strings.EqualFold(p.peek(), "begins_with") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "(" {
+		condition, err := p.predicate()
+		return expr + " AND " + condition, err
+	}
+	sk, err := p.path()
+	if err != nil {
+		return "", err
+	}
+	switch op := p.peek(); {
+	case op != "!=" && op != "<>" && scanComparators[op] != "":
+		p.pos++
+		value, err = p.operand()
+		return expr + " AND " + sk + " " + op + " " + value, err
+	case p.accept("BEGINS_WITH"):
+		value, err = p.operand()
+		return expr + " AND begins_with(" + sk + ", " + value + ")", err
+	case p.accept("BETWEEN"):
+		low, err := p.operand()
+		if err != nil {
+			return "", err
+		}
+		if err = p.expect("AND"); err != nil {
+			return "", err
+		}
+		high, err := p.operand()
+		return expr + " AND " + sk + " BETWEEN " + low + " AND " + high, err
+	}
+	return "", p.unexpected("expected a sort key condition")
+}
+
+// parseScanPlan translates a SELECT statement (without LIMIT and WITH clauses) to a Scan request.
+func parseScanPlan(query string, segments int) (*scanPlan, error) {
+	p, err := newScanParser(query, func(detail string) error {
+		return fmt.Errorf("%w: %s", ErrUnsupportedScan, detail)
+	})
+	if err != nil {
+		return nil, err
+	}
+	plan := p.plan
+	plan.segments = segments
+	if err = p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	if !p.accept("*") {
+		if err = p.projection(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	if err = p.table(); err != nil {
+		return nil, err
+	}
+	if p.accept("WHERE") {
+		if plan.filter, err = p.or(); err != nil {
+			return nil, err
+		}
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.unexpected("expected end of statement")
+	}
+	return plan, nil
+}
+
+// parseNativePlan translates a QUERY or SCAN statement (without WITH clause) to a Query or Scan request. It also
+// returns the value of the LIMIT clause, if any.
+func parseNativePlan(query string) (*scanPlan, *int32, error) {
+	p, err := newScanParser(query, func(detail string) error {
+		return fmt.Errorf("invalid query: %s", detail)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	plan := p.plan
+	isQuery := p.accept("QUERY")
+	if !isQuery {
+		if err = p.expect("SCAN"); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err = p.table(); err != nil {
+		return nil, nil, err
+	}
+	if isQuery {
+		if err = p.expect("WHERE"); err != nil {
+			return nil, nil, err
+		}
+		if plan.keyCondition, err = p.keyCondition(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if p.accept("FILTER") {
+		if plan.filter, err = p.or(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if p.accept("PROJECT") {
+		if err = p.projection(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if isQuery && p.accept("DESC") {
+		plan.descending = true
+	} else if isQuery {
+		p.accept("ASC")
+	}
+	var limit *int32
+	if p.accept("LIMIT") {
+		n, err := strconv.ParseInt(p.peek(), 10, 32)
+		if err != nil || n <= 0 {
+			return nil, nil, p.unexpected("expected a positive LIMIT value")
+		}
+		p.pos++
+		limit = aws.Int32(int32(n))
+	}
+	if p.pos < len(p.tokens) {
+		return nil, nil, p.unexpected("expected end of statement")
+	}
+	return plan, limit, nil
+}
+
+// bind returns the expression attribute values of the plan, binding placeholders to values.
+func (plan *scanPlan) bind(values []driver.NamedValue) (map[string]types.AttributeValue, error) {
+	if len(values) != plan.numParams {
+		return nil, fmt.Errorf("expected %d parameters, received %d", plan.numParams, len(values))
+	}
+	if len(plan.values) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]types.AttributeValue, len(plan.values))
+	for i, operand := range plan.values {
+		av := operand.value
+		if operand.param >= 0 {
+			var err error
+			if av, err = ToAttributeValue(values[operand.param].Value); err != nil {
+				return nil, fmt.Errorf("error marshalling parameter %d-th: %s", operand.param+1, err)
+			}
+		}
+		result[":v"+strconv.Itoa(i)] = av
+	}
+	return result, nil
+}
+
+// optString returns a pointer to s, or nil if s is empty.
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// scanInput builds the Scan request of the plan, binding placeholders to values.
+func (plan *scanPlan) scanInput(values []driver.NamedValue) (*dynamodb.ScanInput, error) {
+	avs, err := plan.bind(values)
+	if err != nil {
+		return nil, err
+	}
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(plan.tableName),
+		IndexName:                 optString(plan.indexName),
+		ProjectionExpression:      optString(plan.projection),
+		FilterExpression:          optString(plan.filter),
+		ExpressionAttributeValues: avs,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}
+	if plan.segments > 1 {
+		input.TotalSegments = aws.Int32(int32(plan.segments))
+	}
+	if len(plan.names) > 0 {
+		input.ExpressionAttributeNames = plan.names
+	}
+	return input, nil
+}
+
+// queryInput builds the Query request of the plan, binding placeholders to values.
+func (plan *scanPlan) queryInput(values []driver.NamedValue) (*dynamodb.QueryInput, error) {
+	avs, err := plan.bind(values)
+	if err != nil {
+		return nil, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(plan.tableName),
+		IndexName:                 optString(plan.indexName),
+		KeyConditionExpression:    aws.String(plan.keyCondition),
+		ProjectionExpression:      optString(plan.projection),
+		FilterExpression:          optString(plan.filter),
+		ExpressionAttributeNames:  plan.names,
+		ExpressionAttributeValues: avs,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}
+	if plan.descending {
+		input.ScanIndexForward = aws.Bool(false)
+	}
+	return input, nil
+}
+
+/*----------------------------------------------------------------------*/
+
+// executeNative executes the plan with the native Query or Scan API and returns the result set. pageSize, if not
+// nil, is the maximum number of items DynamoDB evaluates per call.
+func (c *Conn) executeNative(ctx context.Context, stmt *Stmt, plan *scanPlan, values []driver.NamedValue, pageSize *int32, columnList []string) (driver.Rows, error) {
+	if c.txMode != txNone {
+		return nil, ErrInTx
+	}
+	if pageSize == nil {
+		pageSize = stmt.limit
+	}
+	var consistentRead *bool
+	if opt, ok := stmt.withOpts["CONSISTENT_READ"]; ok {
+		consistentRead = aws.Bool(opt.FirstBool())
+	} else if opt, ok = stmt.withOpts["CONSISTENTREAD"]; ok {
+		consistentRead = aws.Bool(opt.FirstBool())
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	st := &statement{
+		ctx:              ctx,
+		started:          time.Now(),
+		timeout:          c.timeout,
+		resultSetTimeout: c.resultSetTimeout,
+		numberMode:       stmt.numberMode(),
+		widenColumns:     c.widenColumns,
+		widenMaxItems:    c.widenMaxItems,
+		pageTokenSecret:  c.pageTokenSecret,
+		client:           c.client,
+		limit:            aws.ToInt32(stmt.limit),
+		output:           &dynamodb.ExecuteStatementOutput{},
+	}
+	var err error
+	if plan.keyCondition != "" {
+		if st.queryInput, err = plan.queryInput(values); err == nil {
+			st.queryInput.Limit, st.queryInput.ConsistentRead = pageSize, consistentRead
+		}
+	} else if st.scanInput, err = plan.scanInput(values); err == nil {
+		st.scanInput.Limit, st.scanInput.ConsistentRead = pageSize, consistentRead
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := (&ResultResultSet{
+		stmt:       st,
+		columnList: columnList,
+		schema:     lookupTableSchema(plan.tableName),
+	}).init()
+	if result.err != nil {
+		_ = result.Close()
+		return nil, result.err
+	}
+	return result, nil
+}
+
+// queryScan executes the statement as a parallel Scan, see StmtSelect.
+func (s *StmtSelect) queryScan(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
+	values, optFns, _, err := s.pagingOptions(values)
+	if err != nil {
+		return nil, err
+	}
+	var paging dynamodb.ExecuteStatementInput
+	for _, fn := range optFns {
+		fn(&paging)
+	}
+	return s.conn.executeNative(ctx, s.Stmt, s.scan, values, paging.Limit, extractSelectedColumnList(s.query))
+}
+
+// startNative starts fetching the pages of the native request in background, sending them to r.prefetched: a pool of
+// up to maxScanWorkers goroutines for a Scan request, each fetching all pages of the next segment not yet fetched
+// until there is none left, or a single goroutine for a Query request. At most one page per goroutine is buffered.
+// The goroutines stop once all pages (or enough items to reach the limit) are fetched, on error, or when stopPrefetch
+// is called.
+func (r *ResultResultSet) startNative() {
+	var ctx context.Context
+	ctx, r.stopPrefetch = context.WithCancel(r.ctx)
+	segments := 1
+	if r.stmt.scanInput != nil && r.stmt.scanInput.TotalSegments != nil {
+		segments = int(*r.stmt.scanInput.TotalSegments)
+	}
+	workers := min(segments, maxScanWorkers)
+	r.prefetched = make(chan prefetchedPage, workers)
+	r.prefetchDone = make(chan struct{})
+	var numItems atomic.Int64
+	var nextSegment atomic.Int32
+	// fetchSegment fetches all pages of the segment, returning false if the worker should stop.
+	fetchSegment := func(segment int32) bool {
+		var startKey map[string]types.AttributeValue
+		for {
+			if r.stmt.limit > 0 && numItems.Load() >= int64(r.stmt.limit) {
+				return false
+			}
+			page, lastEvaluatedKey, err := r.nativePage(ctx, segment, startKey)
+			select {
+			case r.prefetched <- prefetchedPage{page: page, err: err}:
+			case <-ctx.Done():
+				return false
+			}
+			if err != nil {
+				return false
+			}
+			numItems.Add(int64(len(page.output.Items)))
+			if startKey = lastEvaluatedKey; startKey == nil {
+				return true
+			}
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				segment := nextSegment.Add(1) - 1
+				if int(segment) >= segments || !fetchSegment(segment) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(r.prefetched)
+		close(r.prefetchDone)
+	}()
+}
+
+// nativePage fetches the page of the native request (of the segment, for a parallel Scan request) starting at
+// startKey. It also returns the key to continue from, nil if there is no more page.
+func (r *ResultResultSet) nativePage(ctx context.Context, segment int32, startKey map[string]types.AttributeValue) (resultPage, map[string]types.AttributeValue, error) {
+	ctx, cancel := withTimeout(ctx, r.stmt.timeout)
+	defer cancel()
+	page := &dynamodb.ExecuteStatementOutput{}
+	var lastEvaluatedKey map[string]types.AttributeValue
+	if r.stmt.queryInput != nil {
+		input := *r.stmt.queryInput
+		input.ExclusiveStartKey = startKey
+		output, err := r.stmt.client.Query(ctx, &input)
+		if err != nil {
+			return resultPage{}, nil, err
+		}
+		page.Items, page.ConsumedCapacity, lastEvaluatedKey = output.Items, output.ConsumedCapacity, output.LastEvaluatedKey
+	} else {
+		input := *r.stmt.scanInput
+		input.ExclusiveStartKey = startKey
+		if input.TotalSegments != nil {
+			input.Segment = aws.Int32(segment)
+		}
+		output, err := r.stmt.client.Scan(ctx, &input)
+		if err != nil {
+			return resultPage{}, nil, err
+		}
+		page.Items, page.ConsumedCapacity, lastEvaluatedKey = output.Items, output.ConsumedCapacity, output.LastEvaluatedKey
+	}
+	r.addConsumedCapacity(page.ConsumedCapacity)
+	capacityCollectorFromContext(r.ctx).add(page.ConsumedCapacity)
+	return resultPage{output: page}, lastEvaluatedKey, nil
+}
@@ -0,0 +1,454 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestParseScanPlan(t *testing.T) {
+	testName := "TestParseScanPlan"
+	testData := []struct {
+		name       string
+		query      string
+		table      string
+		index      string
+		projection string
+		filter     string
+		names      map[string]string
+		values     []scanOperand
+	}{
+		{name: "basic", query: `SELECT * FROM "tbl"`, table: "tbl", names: map[string]string{}},
+		{name: "index", query: `select a, "b c" from tbl."idx"`, table: "tbl", index: "idx", projection: "#n0, #n1",
+			names: map[string]string{"#n0": "a", "#n1": "b c"}},
+		{name: "comparisons", query: `SELECT * FROM "tbl" WHERE a = ? AND (b <> 'it''s' OR NOT c.d >= -1.5) AND e != TRUE`, table: "tbl",
+			filter: "#n0 = :v0 AND (#n1 <> :v1 OR NOT #n2.#n3 >= :v2) AND #n4 <> :v3",
+			names:  map[string]string{"#n0": "a", "#n1": "b", "#n2": "c", "#n3": "d", "#n4": "e"},
+			values: []scanOperand{{param: 0}, {param: -1, value: &types.AttributeValueMemberS{Value: "it's"}},
+				{param: -1, value: &types.AttributeValueMemberN{Value: "-1.5"}}, {param: -1, value: &types.AttributeValueMemberBOOL{Value: true}}}},
+		{name: "predicates", query: `SELECT * FROM "tbl" WHERE a BETWEEN ? AND ? OR a IN [1, ?] OR b IS MISSING OR b IS NOT MISSING`, table: "tbl",
+			filter: "#n0 BETWEEN :v0 AND :v1 OR #n0 IN (:v2, :v3) OR attribute_not_exists(#n1) OR attribute_exists(#n1)",
+			names:  map[string]string{"#n0": "a", "#n1": "b"},
+			values: []scanOperand{{param: 0}, {param: 1}, {param: -1, value: &types.AttributeValueMemberN{Value: "1"}}, {param: 2}}},
+		{name: "functions", query: `SELECT * FROM "tbl" WHERE begins_with("a", ?) AND contains(b, 'x') AND EXISTS(c) AND missing(d) AND attribute_type(e, 'S')`, table: "tbl",
+			filter: "begins_with(#n0, :v0) AND contains(#n1, :v1) AND attribute_exists(#n2) AND attribute_not_exists(#n3) AND attribute_type(#n4, :v2)",
+			names:  map[string]string{"#n0": "a", "#n1": "b", "#n2": "c", "#n3": "d", "#n4": "e"},
+			values: []scanOperand{{param: 0}, {param: -1, value: &types.AttributeValueMemberS{Value: "x"}}, {param: -1, value: &types.AttributeValueMemberS{Value: "S"}}}},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			plan, err := parseScanPlan(testCase.query, 2)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if plan.tableName != testCase.table || plan.indexName != testCase.index || plan.projection != testCase.projection || plan.filter != testCase.filter {
+				t.Fatalf("%s failed: unexpected plan %#v", testName+"/"+testCase.name, plan)
+			}
+			if !reflect.DeepEqual(plan.names, testCase.names) || !reflect.DeepEqual(plan.values, testCase.values) {
+				t.Fatalf("%s failed: unexpected names/values %#v/%#v", testName+"/"+testCase.name, plan.names, plan.values)
+			}
+		})
+	}
+
+	for _, query := range []string{
+		`SELECT * FROM "tbl" WHERE a = ? ORDER BY a`,
+		`SELECT a.b FROM "tbl"`,
+		`SELECT * FROM "tbl" WHERE size(a) > 1`,
+		`SELECT * FROM "tbl" WHERE a[0] = 1`,
+		`SELECT * FROM "tbl" WHERE a + 1 = 2`,
+		`SELECT * FROM "tbl" WHERE (a = 1`,
+		`SELECT * FROM "tbl" WHERE a IS NULL`,
+		`SELECT * FROM "tbl" WHERE`,
+	} {
+		if _, err := parseScanPlan(query, 2); !errors.Is(err, ErrUnsupportedScan) {
+			t.Fatalf("%s failed: expected ErrUnsupportedScan for %s but received %v", testName, query, err)
+		}
+	}
+}
+
+// _scanStubServer returns a server that serves Scan requests: each segment has numPages pages of 2 items each,
+// with ids "<segment>-<n>". Scans of segment failSegment (if not negative) fail. Requests are recorded in calls.
+func _scanStubServer(numPages, failSegment int, delay time.Duration, calls *[]map[string]interface{}) *stubDynamoDBServer {
+	var lock sync.Mutex
+	return newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		if op != "Scan" {
+			return stubError(400, "ValidationException", "unexpected operation "+op)
+		}
+		lock.Lock()
+		*calls = append(*calls, req)
+		lock.Unlock()
+		time.Sleep(delay)
+		segment := int(req["Segment"].(float64))
+		if segment == failSegment {
+			return stubError(400, "ValidationException", "segment failed")
+		}
+		page := 0
+		if key, ok := req["ExclusiveStartKey"].(map[string]interface{}); ok {
+			_, _ = fmt.Sscanf(key["page"].(map[string]interface{})["N"].(string), "%d", &page)
+		}
+		body := map[string]interface{}{
+			"Items": []interface{}{
+				map[string]interface{}{"id": map[string]interface{}{"S": fmt.Sprintf("%d-%d", segment, page*2)}},
+				map[string]interface{}{"id": map[string]interface{}{"S": fmt.Sprintf("%d-%d", segment, page*2+1)}},
+			},
+			"ConsumedCapacity": map[string]interface{}{"TableName": "tbltest", "CapacityUnits": 0.5},
+		}
+		if page+1 < numPages {
+			body["LastEvaluatedKey"] = map[string]interface{}{"page": map[string]interface{}{"N": fmt.Sprintf("%d", page+1)}}
+		}
+		return stubResponse{body: body}
+	})
+}
+
+func _countQueryRows(db *sql.DB, query string) (int, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	return count, rows.Err()
+}
+
+func TestStmtSelect_segments(t *testing.T) {
+	testName := "TestStmtSelect_segments"
+	var calls []map[string]interface{}
+	server := _scanStubServer(3, -1, 0, &calls)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	ctx, collector := WithCapacityCollector(context.Background())
+	rows, err := db.QueryContext(ctx, `SELECT id FROM "tbltest"."idx" WHERE begins_with(id, ?) WITH Segments=4 WITH PageSize=2 WITH ConsistentRead=true`, "x")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	_ = rows.Close()
+	sort.Strings(ids)
+	var expected []string
+	for segment := 0; segment < 4; segment++ {
+		for i := 0; i < 6; i++ {
+			expected = append(expected, fmt.Sprintf("%d-%d", segment, i))
+		}
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, ids)
+	}
+	if len(calls) != 12 {
+		t.Fatalf("%s failed: expected %d calls but received %d", testName, 12, len(calls))
+	}
+	req := calls[0]
+	if req["TableName"] != "tbltest" || req["IndexName"] != "idx" || req["TotalSegments"] != 4.0 || req["Limit"] != 2.0 ||
+		req["ConsistentRead"] != true || req["ProjectionExpression"] != "#n0" || req["FilterExpression"] != "begins_with(#n0, :v0)" ||
+		!reflect.DeepEqual(req["ExpressionAttributeValues"], map[string]interface{}{":v0": map[string]interface{}{"S": "x"}}) {
+		t.Fatalf("%s failed: unexpected request %#v", testName, req)
+	}
+	if capacity, _ := collector.ConsumedCapacity(); capacity == nil || *capacity.CapacityUnits != 6 {
+		t.Fatalf("%s failed: expected 6 capacity units but received %#v", testName, capacity)
+	}
+
+	// LIMIT stops the segments
+	if count, err := _countQueryRows(db, `SELECT * FROM "tbltest" LIMIT 3 WITH Segments=2`); err != nil || count != 3 {
+		t.Fatalf("%s failed: expected %d rows but received %d (error %v)", testName, 3, count, err)
+	}
+	if n := server.numCalls("Scan") - 12; n > 4 {
+		t.Fatalf("%s failed: expected no more than %d calls but received %d", testName, 4, n)
+	}
+
+	// resume tokens are not supported
+	conn, _ := db.Conn(context.Background())
+	defer func() { _ = conn.Close() }()
+	_ = conn.Raw(func(driverConn any) error {
+		stmt, _ := driverConn.(*Conn).Prepare(`SELECT * FROM "tbltest" WITH Segments=2`)
+		rows, err := stmt.(*StmtSelect).Query(nil)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		defer func() { _ = rows.Close() }()
+		if _, err = rows.(*ResultResultSet).ResumeToken(); !errors.Is(err, ErrUnsupportedScan) {
+			t.Fatalf("%s failed: expected ErrUnsupportedScan but received %v", testName, err)
+		}
+		return nil
+	})
+}
+
+func TestStmtSelect_segments_workers(t *testing.T) {
+	testName := "TestStmtSelect_segments_workers"
+	var lock sync.Mutex
+	inFlight, maxInFlight, segments := 0, 0, map[int]bool{}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		lock.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		segments[int(req["Segment"].(float64))] = true
+		lock.Unlock()
+		time.Sleep(2 * time.Millisecond)
+		lock.Lock()
+		inFlight--
+		lock.Unlock()
+		return stubResponse{body: map[string]interface{}{"Items": []interface{}{
+			map[string]interface{}{"id": map[string]interface{}{"S": fmt.Sprintf("%v", req["Segment"])}},
+		}}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	numSegments := 3 * maxScanWorkers
+	if count, err := _countQueryRows(db, fmt.Sprintf(`SELECT * FROM "tbltest" WITH Segments=%d`, numSegments)); err != nil || count != numSegments {
+		t.Fatalf("%s failed: expected %d rows but received %d (error %v)", testName, numSegments, count, err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(segments) != numSegments {
+		t.Fatalf("%s failed: expected %d segments to be scanned but received %d", testName, numSegments, len(segments))
+	}
+	if maxInFlight > maxScanWorkers {
+		t.Fatalf("%s failed: expected no more than %d concurrent calls but received %d", testName, maxScanWorkers, maxInFlight)
+	}
+}
+
+func TestStmtSelect_segments_error(t *testing.T) {
+	testName := "TestStmtSelect_segments_error"
+	var calls []map[string]interface{}
+	server := _scanStubServer(50, 2, 5*time.Millisecond, &calls)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("MaxAttempts=1"))
+	defer func() { _ = db.Close() }()
+
+	_, err := _countQueryRows(db, `SELECT * FROM "tbltest" WITH Segments=3`)
+	if err == nil || !strings.Contains(err.Error(), "segment failed") {
+		t.Fatalf("%s failed: expected error of the failed segment but received %v", testName, err)
+	}
+	// the other segments are stopped
+	time.Sleep(50 * time.Millisecond)
+	n := server.numCalls("Scan")
+	time.Sleep(50 * time.Millisecond)
+	if server.numCalls("Scan") != n {
+		t.Fatalf("%s failed: segments are scanned after the error", testName)
+	}
+}
+
+func TestStmtSelect_segments_cancel(t *testing.T) {
+	testName := "TestStmtSelect_segments_cancel"
+	var calls []map[string]interface{}
+	server := _scanStubServer(50, -1, 20*time.Millisecond, &calls)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("MaxAttempts=1"))
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := db.QueryContext(ctx, `SELECT * FROM "tbltest" WITH Segments=2`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = rows.Close() }()
+	rows.Next()
+	cancel()
+	for rows.Next() {
+	}
+	if err = rows.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("%s failed: expected context.Canceled but received %v", testName, err)
+	}
+}
+
+func TestParseNativePlan(t *testing.T) {
+	testName := "TestParseNativePlan"
+	testData := []struct {
+		name         string
+		query        string
+		keyCondition string
+		filter       string
+		columns      []string
+		descending   bool
+		limit        int32
+	}{
+		{name: "partition_key", query: `QUERY "tbl" WHERE pk = ?`, keyCondition: "#n0 = :v0"},
+		{name: "begins_with", query: `query tbl WHERE pk = ? AND sk BEGINS_WITH 'a'`, keyCondition: "#n0 = :v0 AND begins_with(#n1, :v1)"},
+		{name: "begins_with_function", query: `QUERY tbl WHERE pk = ? AND begins_with(sk, ?) DESC`, keyCondition: "#n0 = :v0 AND begins_with(#n1, :v1)", descending: true},
+		{name: "between", query: `QUERY tbl."idx" WHERE pk = 1 AND sk BETWEEN ? AND ? ASC LIMIT 5`, keyCondition: "#n0 = :v0 AND #n1 BETWEEN :v1 AND :v2", limit: 5},
+		{name: "all_clauses", query: `QUERY tbl WHERE pk = ? AND sk >= ? FILTER a <> ? OR b IS MISSING PROJECT pk, a DESC LIMIT 10`,
+			keyCondition: "#n0 = :v0 AND #n1 >= :v1", filter: "#n2 <> :v2 OR attribute_not_exists(#n3)", columns: []string{"pk", "a"}, descending: true, limit: 10},
+		{name: "scan", query: `SCAN "tbl" FILTER a = ? PROJECT a LIMIT 3`, filter: "#n0 = :v0", columns: []string{"a"}, limit: 3},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			plan, limit, err := parseNativePlan(testCase.query)
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if plan.keyCondition != testCase.keyCondition || plan.filter != testCase.filter || plan.descending != testCase.descending ||
+				!reflect.DeepEqual(plan.columns, testCase.columns) {
+				t.Fatalf("%s failed: unexpected plan %#v", testName+"/"+testCase.name, plan)
+			}
+			if (testCase.limit == 0) != (limit == nil) || (limit != nil && *limit != testCase.limit) {
+				t.Fatalf("%s failed: expected limit %d but received %v", testName+"/"+testCase.name, testCase.limit, limit)
+			}
+		})
+	}
+
+	for _, query := range []string{
+		`QUERY tbl`,
+		`QUERY tbl WHERE pk > ?`,
+		`QUERY tbl WHERE pk = ? OR sk = ?`,
+		`QUERY tbl WHERE pk = ? AND sk <> ?`,
+		`QUERY tbl WHERE pk = ? AND contains(sk, ?)`,
+		`QUERY tbl WHERE pk = ? LIMIT 0`,
+		`QUERY tbl WHERE pk = ? PROJECT a FILTER a = 1`,
+		`SCAN tbl WHERE a = 1`,
+		`SCAN tbl DESC`,
+	} {
+		if _, _, err := parseNativePlan(query); err == nil {
+			t.Fatalf("%s failed: expected error for %s", testName, query)
+		}
+	}
+}
+
+func Test_Stmt_Query_parse(t *testing.T) {
+	testName := "Test_Stmt_Query_parse"
+	testData := []struct {
+		name      string
+		sql       string
+		numInput  int
+		mustError bool
+	}{
+		{name: "query", sql: `QUERY "tbl" WHERE pk = ? AND sk > ? FILTER a = ? WITH ConsistentRead=true WITH PageSize=10`, numInput: 3},
+		{name: "query_multiline", sql: "QUERY \"tbl\"\nWHERE pk = ?\nDESC\nWITH number_mode=json", numInput: 1},
+		{name: "scan", sql: `SCAN "tbl" LIMIT 10 WITH Segments=4`},
+		{name: "query_segments", sql: `QUERY "tbl" WHERE pk = ? WITH Segments=4`, mustError: true},
+		{name: "invalid_page_size", sql: `SCAN "tbl" WITH PageSize=0`, mustError: true},
+		{name: "placeholder_option", sql: `SCAN "tbl" WITH PageSize=?`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			stmt, err := parseQuery(nil, testCase.sql)
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if stmt.NumInput() != testCase.numInput {
+				t.Fatalf("%s failed: expected %d input parameters but received %d", testName+"/"+testCase.name, testCase.numInput, stmt.NumInput())
+			}
+		})
+	}
+}
+
+func TestStmtQuery(t *testing.T) {
+	testName := "TestStmtQuery"
+	var lock sync.Mutex
+	var requests []map[string]interface{}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		lock.Lock()
+		requests = append(requests, req)
+		lock.Unlock()
+		page := 0
+		if key, ok := req["ExclusiveStartKey"].(map[string]interface{}); ok {
+			_, _ = fmt.Sscanf(key["sk"].(map[string]interface{})["N"].(string), "%d", &page)
+		}
+		body := map[string]interface{}{"Items": []interface{}{
+			map[string]interface{}{"pk": map[string]interface{}{"S": "p"}, "sk": map[string]interface{}{"N": fmt.Sprintf("%d", page)}},
+		}}
+		if page < 2 {
+			body["LastEvaluatedKey"] = map[string]interface{}{"sk": map[string]interface{}{"N": fmt.Sprintf("%d", page+1)}}
+		}
+		return stubResponse{body: body}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.Query(`QUERY "tbltest"."idx" WHERE pk = ? AND sk BETWEEN ? AND ? PROJECT sk, pk DESC WITH ConsistentRead=true`, "p", 0, 10)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if cols, _ := rows.Columns(); !reflect.DeepEqual(cols, []string{"sk", "pk"}) {
+		t.Fatalf("%s failed: unexpected columns %#v", testName, cols)
+	}
+	var sks []float64
+	for rows.Next() {
+		var sk float64
+		var pk string
+		if err = rows.Scan(&sk, &pk); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		sks = append(sks, sk)
+	}
+	if err = rows.Err(); err != nil || !reflect.DeepEqual(sks, []float64{0, 1, 2}) {
+		t.Fatalf("%s failed: unexpected %#v (error %v)", testName, sks, err)
+	}
+	_ = rows.Close()
+
+	if server.numCalls("Query") != 3 || server.numCalls("ExecuteStatement") != 0 {
+		t.Fatalf("%s failed: expected %d calls to Query but received %d", testName, 3, server.numCalls("Query"))
+	}
+	req := requests[0]
+	expected := map[string]interface{}{
+		"TableName":                 "tbltest",
+		"IndexName":                 "idx",
+		"KeyConditionExpression":    "#n0 = :v0 AND #n1 BETWEEN :v1 AND :v2",
+		"ProjectionExpression":      "#n1, #n0",
+		"ScanIndexForward":          false,
+		"ConsistentRead":            true,
+		"ReturnConsumedCapacity":    "TOTAL",
+		"ExpressionAttributeNames":  map[string]interface{}{"#n0": "pk", "#n1": "sk"},
+		"ExpressionAttributeValues": map[string]interface{}{":v0": map[string]interface{}{"S": "p"}, ":v1": map[string]interface{}{"N": "0"}, ":v2": map[string]interface{}{"N": "10"}},
+	}
+	if !reflect.DeepEqual(req, expected) {
+		t.Fatalf("%s failed: expected request %#v but received %#v", testName, expected, req)
+	}
+
+	// LIMIT stops fetching pages
+	if count, err := _countQueryRows(db, `QUERY "tbltest" WHERE pk = 'p' LIMIT 1`); err != nil || count != 1 {
+		t.Fatalf("%s failed: expected %d row but received %d (error %v)", testName, 1, count, err)
+	}
+	if n := server.numCalls("Query"); n != 4 {
+		t.Fatalf("%s failed: expected %d calls to Query but received %d", testName, 4, n)
+	}
+	if _, err = db.Exec(`QUERY "tbltest" WHERE pk = ?`, "p"); err == nil {
+		t.Fatalf("%s failed: expected error for Exec", testName)
+	}
+}
+
+func TestStmtScan(t *testing.T) {
+	testName := "TestStmtScan"
+	var calls []map[string]interface{}
+	server := _scanStubServer(2, -1, 0, &calls)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	if count, err := _countQueryRows(db, `SCAN "tbltest" FILTER attribute_type(id, 'S') WITH Segments=3`); err != nil || count != 12 {
+		t.Fatalf("%s failed: expected %d rows but received %d (error %v)", testName, 12, count, err)
+	}
+	if calls[0]["FilterExpression"] != "attribute_type(#n0, :v0)" || calls[0]["TotalSegments"] != 3.0 {
+		t.Fatalf("%s failed: unexpected request %#v", testName, calls[0])
+	}
+}
@@ -0,0 +1,288 @@
+package godynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	// ErrScanDenied is returned, if DenyScans is enabled, when executing a SELECT, UPDATE or DELETE statement whose
+	// WHERE clause has no equality condition on the partition key, i.e. which would scan the whole table.
+	//
+	// @Available since v1.4.0
+	ErrScanDenied = errors.New("statement would scan the table")
+)
+
+// keySchema holds the names of the key attributes of a table or an index.
+type keySchema struct {
+	partitionKey string
+	sortKey      string
+}
+
+// tableKeySchemas holds the key schemas of a table and of its indexes.
+type tableKeySchemas struct {
+	table   keySchema
+	indexes map[string]keySchema
+	missing map[string]time.Time // indexes not found on the table, with the time they were looked up
+}
+
+// missingIndexTTL is how long an index not found on a table is cached: the table is described again afterwards, as
+// the index may have been created since.
+const missingIndexTTL = time.Minute
+
+// keySchemaCache caches the key schemas fetched via DescribeTable, shared by the connections of a Connector.
+type keySchemaCache struct {
+	lock   sync.RWMutex
+	tables map[string]*tableKeySchemas
+}
+
+func newKeySchemaCache() *keySchemaCache {
+	return &keySchemaCache{tables: map[string]*tableKeySchemas{}}
+}
+
+func toKeySchema(elements []types.KeySchemaElement) keySchema {
+	var schema keySchema
+	for _, element := range elements {
+		if element.KeyType == types.KeyTypeHash {
+			schema.partitionKey = aws.ToString(element.AttributeName)
+		} else if element.KeyType == types.KeyTypeRange {
+			schema.sortKey = aws.ToString(element.AttributeName)
+		}
+	}
+	return schema
+}
+
+// keySchemaOf returns the key schema of a table, or of one of its indexes if indexName is not empty. Key schemas are
+// cached; the table is described again if the index is not found in the cache, as it may have been created since.
+// Indexes not found on the table are cached for missingIndexTTL.
+func (c *Conn) keySchemaOf(ctx context.Context, tableName, indexName string) (keySchema, error) {
+	if c.keySchemas != nil {
+		c.keySchemas.lock.RLock()
+		schemas := c.keySchemas.tables[tableName]
+		c.keySchemas.lock.RUnlock()
+		if schemas != nil {
+			if indexName == "" {
+				return schemas.table, nil
+			}
+			if schema, ok := schemas.indexes[indexName]; ok {
+				return schema, nil
+			}
+			if lookedUp, ok := schemas.missing[indexName]; ok && time.Since(lookedUp) < missingIndexTTL {
+				return keySchema{}, fmt.Errorf("index %s not found on table %s", indexName, tableName)
+			}
+		}
+	}
+
+	reqCtx, cancel := c.requestContext(ctx)
+	defer cancel()
+	output, err := c.client.DescribeTable(reqCtx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return keySchema{}, err
+	}
+	schemas := &tableKeySchemas{table: toKeySchema(output.Table.KeySchema), indexes: map[string]keySchema{}, missing: map[string]time.Time{}}
+	for _, gsi := range output.Table.GlobalSecondaryIndexes {
+		schemas.indexes[aws.ToString(gsi.IndexName)] = toKeySchema(gsi.KeySchema)
+	}
+	for _, lsi := range output.Table.LocalSecondaryIndexes {
+		schemas.indexes[aws.ToString(lsi.IndexName)] = toKeySchema(lsi.KeySchema)
+	}
+	if _, ok := schemas.indexes[indexName]; indexName != "" && !ok {
+		schemas.missing[indexName] = time.Now()
+	}
+	if c.keySchemas != nil {
+		c.keySchemas.lock.Lock()
+		c.keySchemas.tables[tableName] = schemas
+		c.keySchemas.lock.Unlock()
+	}
+	if indexName == "" {
+		return schemas.table, nil
+	}
+	schema, ok := schemas.indexes[indexName]
+	if !ok {
+		return keySchema{}, fmt.Errorf("index %s not found on table %s", indexName, tableName)
+	}
+	return schema, nil
+}
+
+// guardTokens splits a statement into tokens. Unlike newScanParser, characters not recognized are returned as
+// single-character tokens, since the guard only looks at the key conditions of the WHERE clause.
+func guardTokens(query string) []string {
+	var tokens []string
+	for pos := 0; pos < len(query); {
+		token := reScanToken.FindString(query[pos:])
+		if token == "" {
+			token = query[pos : pos+1]
+		}
+		pos += len(token)
+		if strings.TrimSpace(token) != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// unquoteName returns the name of an identifier token, or an empty string if the token is not an identifier.
+func unquoteName(token string) string {
+	if strings.HasPrefix(token, `"`) {
+		if len(token) < 2 || !strings.HasSuffix(token, `"`) {
+			// unterminated quoted identifier
+			return ""
+		}
+		return token[1 : len(token)-1]
+	}
+	if token != "" && (token[0] == '_' || token[0] >= 'A' && token[0] <= 'Z' || token[0] >= 'a' && token[0] <= 'z') {
+		return token
+	}
+	return ""
+}
+
+// guardTarget extracts the table name, index name and WHERE clause tokens of a SELECT, UPDATE or DELETE statement.
+func guardTarget(query string) (tableName, indexName string, where []string) {
+	tokens := guardTokens(query)
+	i := 0
+	switch {
+	case len(tokens) > 0 && strings.EqualFold(tokens[0], "UPDATE"):
+		i = 1
+	default:
+		for i < len(tokens) && !strings.EqualFold(tokens[i], "FROM") {
+			i++
+		}
+		i++
+	}
+	if i >= len(tokens) {
+		return "", "", nil
+	}
+	tableName = unquoteName(tokens[i])
+	if i+2 < len(tokens) && tokens[i+1] == "." {
+		indexName = unquoteName(tokens[i+2])
+		i += 2
+	}
+	for depth := 0; i < len(tokens); i++ {
+		switch {
+		case tokens[i] == "(" || tokens[i] == "[" || tokens[i] == "{":
+			depth++
+		case tokens[i] == ")" || tokens[i] == "]" || tokens[i] == "}":
+			depth--
+		case depth == 0 && strings.EqualFold(tokens[i], "WHERE"):
+			where = tokens[i+1:]
+			for j, token := range where {
+				if strings.EqualFold(token, "RETURNING") {
+					where = where[:j]
+					break
+				}
+			}
+			return tableName, indexName, where
+		}
+	}
+	return tableName, indexName, nil
+}
+
+// splitTopLevel splits tokens on the keyword found outside parentheses. The AND of a BETWEEN predicate is not a
+// separator.
+func splitTopLevel(tokens []string, keyword string) [][]string {
+	var parts [][]string
+	start, depth, between := 0, 0, false
+	for i, token := range tokens {
+		switch {
+		case token == "(" || token == "[" || token == "{":
+			depth++
+		case token == ")" || token == "]" || token == "}":
+			depth--
+		case depth == 0 && strings.EqualFold(token, "BETWEEN"):
+			between = true
+		case depth == 0 && strings.EqualFold(token, keyword):
+			if keyword == "AND" && between {
+				between = false
+				continue
+			}
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+// enclosed returns true if tokens are wrapped in a single pair of parentheses.
+func enclosed(tokens []string) bool {
+	if len(tokens) < 2 || tokens[0] != "(" || tokens[len(tokens)-1] != ")" {
+		return false
+	}
+	depth := 0
+	for i, token := range tokens {
+		if token == "(" {
+			depth++
+		} else if token == ")" {
+			depth--
+		}
+		if depth == 0 && i < len(tokens)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// constrainsKey returns true if the condition restricts the attribute key to a set of values, i.e. if every branch of
+// its top-level OR has an equality or IN condition on key, outside of NOT.
+func constrainsKey(condition []string, key string) bool {
+	for enclosed(condition) {
+		condition = condition[1 : len(condition)-1]
+	}
+	if len(condition) == 0 {
+		return false
+	}
+	if branches := splitTopLevel(condition, "OR"); len(branches) > 1 {
+		for _, branch := range branches {
+			if !constrainsKey(branch, key) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, conjunct := range splitTopLevel(condition, "AND") {
+		if enclosed(conjunct) {
+			if constrainsKey(conjunct, key) {
+				return true
+			}
+			continue
+		}
+		if len(conjunct) < 3 {
+			continue
+		}
+		if unquoteName(conjunct[0]) == key && (conjunct[1] == "=" || strings.EqualFold(conjunct[1], "IN")) {
+			return true
+		}
+		if len(conjunct) == 3 && conjunct[1] == "=" && unquoteName(conjunct[2]) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// checkScan returns ErrScanDenied if the WHERE clause of the SELECT, UPDATE or DELETE statement has no equality
+// condition on the partition key of the table (or index) it targets.
+func (c *Conn) checkScan(ctx context.Context, query string) error {
+	tableName, indexName, where := guardTarget(query)
+	if tableName == "" {
+		return fmt.Errorf("%w: table name not found", ErrScanDenied)
+	}
+	schema, err := c.keySchemaOf(ctx, tableName, indexName)
+	if err != nil {
+		return err
+	}
+	if schema.partitionKey == "" || constrainsKey(where, schema.partitionKey) {
+		return nil
+	}
+	target := "table " + tableName
+	if indexName != "" {
+		target = "index " + indexName + " of " + target
+	}
+	return fmt.Errorf("%w: WHERE clause has no equality condition on partition key %q of %s", ErrScanDenied, schema.partitionKey, target)
+}
@@ -0,0 +1,163 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConstrainsKey(t *testing.T) {
+	testName := "TestConstrainsKey"
+	testData := []struct {
+		where    string
+		expected bool
+	}{
+		{where: `pk = ?`, expected: true},
+		{where: `"pk"='a' AND sk > 1`, expected: true},
+		{where: `sk BETWEEN 1 AND 2 AND ? = pk`, expected: true},
+		{where: `pk IN [?, ?]`, expected: true},
+		{where: `(pk = 1 OR pk = 2) AND status = 'x'`, expected: true},
+		{where: `pk = 1 OR (pk = 2 AND sk = 3)`, expected: true},
+		{where: `status = 'x'`, expected: false},
+		{where: `pk > ?`, expected: false},
+		{where: `pk = 1 OR status = 'x'`, expected: false},
+		{where: `NOT pk = 1`, expected: false},
+		{where: `begins_with(pk, 'a')`, expected: false},
+		{where: `pkey = 1`, expected: false},
+		{where: ``, expected: false},
+		{where: `"pk = ?`, expected: false},
+	}
+	for _, testCase := range testData {
+		if got := constrainsKey(guardTokens(testCase.where), "pk"); got != testCase.expected {
+			t.Fatalf("%s failed: expected %v for <%s> but received %v", testName, testCase.expected, testCase.where, got)
+		}
+	}
+}
+
+func TestGuardTarget(t *testing.T) {
+	testName := "TestGuardTarget"
+	testData := []struct {
+		query, table, index, where string
+	}{
+		{query: `SELECT * FROM "tbl" WHERE pk = ?`, table: "tbl", where: "pk = ?"},
+		{query: `SELECT a, b FROM "tbl"."idx" WHERE a = ? AND b > 1`, table: "tbl", index: "idx", where: "a = ? AND b > 1"},
+		{query: `SELECT * FROM tbl`, table: "tbl"},
+		{query: `UPDATE "tbl" SET a = ? WHERE pk = ? RETURNING ALL OLD *`, table: "tbl", where: "pk = ?"},
+		{query: `DELETE FROM tbl WHERE pk = 'a;b'`, table: "tbl", where: "pk = 'a;b'"},
+		{query: `DELETE FROM "t" WHERE "pk = ?`, table: "t", where: `" pk = ?`},
+		{query: `SELECT * FROM " WHERE pk = ?`, table: "", where: "pk = ?"},
+	}
+	for _, testCase := range testData {
+		table, index, where := guardTarget(testCase.query)
+		if table != testCase.table || index != testCase.index || strings.Join(where, " ") != testCase.where {
+			t.Fatalf("%s failed: unexpected (%s, %s, %s) for <%s>", testName, table, index, strings.Join(where, " "), testCase.query)
+		}
+	}
+}
+
+func TestDenyScans(t *testing.T) {
+	testName := "TestDenyScans"
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		if op == "DescribeTable" {
+			return stubResponse{body: map[string]interface{}{"Table": map[string]interface{}{
+				"TableName": req["TableName"],
+				"KeySchema": []interface{}{
+					map[string]interface{}{"AttributeName": "pk", "KeyType": "HASH"},
+					map[string]interface{}{"AttributeName": "sk", "KeyType": "RANGE"},
+				},
+				"GlobalSecondaryIndexes": []interface{}{
+					map[string]interface{}{"IndexName": "idx_status", "KeySchema": []interface{}{
+						map[string]interface{}{"AttributeName": "status", "KeyType": "HASH"},
+					}},
+				},
+			}}}
+		}
+		return stubResponse{body: map[string]interface{}{"Items": []interface{}{}}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("DenyScans=true"))
+	defer func() { _ = db.Close() }()
+	db.SetMaxOpenConns(2)
+
+	testData := []struct {
+		name    string
+		query   string
+		args    []interface{}
+		exec    bool
+		missing string
+	}{
+		{name: "select_key", query: `SELECT * FROM "orders" WHERE pk = ? AND sk > ?`, args: []interface{}{"a", 1}},
+		{name: "select_scan", query: `SELECT * FROM "orders" WHERE status = ?`, args: []interface{}{"x"}, missing: `"pk" of table orders`},
+		{name: "select_no_where", query: `SELECT * FROM "orders" LIMIT 10`, missing: `"pk" of table orders`},
+		{name: "select_allow_scan", query: `SELECT * FROM "orders" WHERE status = ? WITH AllowScan=true`, args: []interface{}{"x"}},
+		{name: "select_index", query: `SELECT * FROM "orders"."idx_status" WHERE status = ?`, args: []interface{}{"x"}},
+		{name: "select_index_scan", query: `SELECT * FROM "orders"."idx_status" WHERE pk = ?`, args: []interface{}{"a"}, missing: `"status" of index idx_status of table orders`},
+		{name: "select_segments", query: `SELECT * FROM "orders" WITH Segments=2`},
+		{name: "select_unterminated_name", query: `SELECT * FROM "orders" WHERE "pk = ?`, args: []interface{}{"a"}, missing: `"pk" of table orders`},
+		{name: "select_unterminated_table", query: `SELECT * FROM " WHERE pk = ?`, args: []interface{}{"a"}, missing: "table name not found"},
+		{name: "update_key", query: `UPDATE "orders" SET status = ? WHERE pk = ? AND sk = ?`, args: []interface{}{"x", "a", 1}, exec: true},
+		{name: "update_scan", query: `UPDATE "orders" SET status = ? WHERE sk = ?`, args: []interface{}{"x", 1}, exec: true, missing: `"pk" of table orders`},
+		{name: "delete_scan", query: `DELETE FROM "orders" WHERE status = ?`, args: []interface{}{"x"}, exec: true, missing: `"pk" of table orders`},
+		{name: "delete_unterminated_name", query: `DELETE FROM "orders" WHERE "pk = ?`, args: []interface{}{"a"}, exec: true, missing: `"pk" of table orders`},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			var err error
+			if testCase.exec {
+				_, err = db.ExecContext(context.Background(), testCase.query, testCase.args...)
+			} else {
+				var rows *sql.Rows
+				if rows, err = db.QueryContext(context.Background(), testCase.query, testCase.args...); err == nil {
+					_ = rows.Close()
+				}
+			}
+			if testCase.missing == "" {
+				if err != nil {
+					t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrScanDenied) || !strings.Contains(err.Error(), testCase.missing) {
+				t.Fatalf("%s failed: expected ErrScanDenied naming %s but received %v", testName+"/"+testCase.name, testCase.missing, err)
+			}
+		})
+	}
+	if n := server.numCalls("DescribeTable"); n != 1 {
+		t.Fatalf("%s failed: expected key schemas to be cached but DescribeTable was called %d times", testName, n)
+	}
+
+	// indexes not found on the table are cached too
+	for i := 0; i < 2; i++ {
+		if _, err := db.Query(`SELECT * FROM "orders"."idx_none" WHERE pk = ?`, "a"); err == nil || !strings.Contains(err.Error(), "index idx_none not found") {
+			t.Fatalf("%s failed: expected index not found error but received %v", testName, err)
+		}
+	}
+	if n := server.numCalls("DescribeTable"); n != 2 {
+		t.Fatalf("%s failed: expected missing indexes to be cached but DescribeTable was called %d times", testName, n)
+	}
+	// ... until they expire
+	conn, _ := db.Conn(context.Background())
+	_ = conn.Raw(func(driverConn any) error {
+		cache := driverConn.(*Conn).keySchemas
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+		cache.tables["orders"].missing["idx_none"] = time.Now().Add(-missingIndexTTL)
+		return nil
+	})
+	_ = conn.Close()
+	_, _ = db.Query(`SELECT * FROM "orders"."idx_none" WHERE pk = ?`, "a")
+	if n := server.numCalls("DescribeTable"); n != 3 {
+		t.Fatalf("%s failed: expected the table to be described again once the missing index expired but DescribeTable was called %d times", testName, n)
+	}
+
+	db2, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db2.Close() }()
+	if rows, err := db2.Query(`SELECT * FROM "orders" WHERE status = ?`, "x"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else {
+		_ = rows.Close()
+	}
+}
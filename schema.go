@@ -0,0 +1,152 @@
+package godynamo
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Column describes an attribute of a table registered via RegisterTableSchema.
+//
+// @Available since v1.4.0
+type Column struct {
+	// Name is the name of the attribute.
+	Name string
+
+	// Type is the DynamoDB data type of the attribute: S, N, B, BOOL, SS, NS, BS, L, M or NULL. If empty, the type
+	// is derived from the values returned.
+	Type string
+
+	// KeyType is the role of the attribute in the table's primary key (types.KeyTypeHash or types.KeyTypeRange),
+	// empty for non-key attributes.
+	KeyType types.KeyType
+}
+
+var (
+	// ErrWidenColumnsLimit is returned when reading a result set whose columns are widened (see Config.WidenColumns)
+	// if it has more items than Config.WidenColumnsMaxItems.
+	//
+	// @Available since v1.4.0
+	ErrWidenColumnsLimit = errors.New("too many items to widen the columns of the result set")
+)
+
+// tableSchemas holds the table schemas registered via RegisterTableSchema.
+var (
+	tableSchemasLock = &sync.RWMutex{}
+	tableSchemas     = map[string][]Column{}
+)
+
+// RegisterTableSchema registers the columns of a table. Result sets of "SELECT *" statements on the table (or its
+// indexes) then always have the registered columns, in the same order regardless of the returned items: the key
+// attributes first (partition key, then sort key), followed by other columns in the registered order. Attributes not
+// registered are not returned, and registered attributes missing from an item are returned as nil.
+//
+// Example:
+//
+//	godynamo.RegisterTableSchema("session", []godynamo.Column{
+//		{Name: "app", Type: "S", KeyType: types.KeyTypeHash},
+//		{Name: "user", Type: "S", KeyType: types.KeyTypeRange},
+//		{Name: "active", Type: "BOOL"},
+//		{Name: "duration", Type: "N"},
+//	})
+//
+// @Available since v1.4.0
+func RegisterTableSchema(table string, columns []Column) {
+	schema := make([]Column, len(columns))
+	for i, col := range columns {
+		schema[i] = Column{Name: col.Name, Type: strings.ToUpper(strings.TrimSpace(col.Type)), KeyType: col.KeyType}
+	}
+	keyOrder := func(col Column) int {
+		switch col.KeyType {
+		case types.KeyTypeHash:
+			return 0
+		case types.KeyTypeRange:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(schema, func(i, j int) bool {
+		return keyOrder(schema[i]) < keyOrder(schema[j])
+	})
+
+	tableSchemasLock.Lock()
+	defer tableSchemasLock.Unlock()
+	tableSchemas[table] = schema
+}
+
+// DeregisterTableSchema removes the registered schema of a table.
+//
+// @Available since v1.4.0
+func DeregisterTableSchema(table string) {
+	tableSchemasLock.Lock()
+	defer tableSchemasLock.Unlock()
+	delete(tableSchemas, table)
+}
+
+// lookupTableSchema returns the registered schema of a table, or nil if none.
+func lookupTableSchema(table string) []Column {
+	if table == "" {
+		return nil
+	}
+	tableSchemasLock.RLock()
+	defer tableSchemasLock.RUnlock()
+	return tableSchemas[table]
+}
+
+var (
+	typeAny = reflect.TypeOf((*interface{})(nil)).Elem()
+	typeB   = reflect.TypeOf([]byte{})
+)
+
+// scanType returns the Go type values of a DynamoDB data type are returned as, using the number mode m. Numbers of
+// NumberModeInt64, and number sets of that mode, are reported as interface{}, since non-integral numbers are returned
+// as float64.
+func (m NumberMode) scanType(dataType string) reflect.Type {
+	switch dataType {
+	case "S":
+		return typeS
+	case "B":
+		return typeB
+	case "BOOL":
+		return typeBool
+	case "SS":
+		return typeStringSet
+	case "NS":
+		switch m {
+		case NumberModeJSON:
+			return reflect.TypeOf([]json.Number{})
+		case NumberModeString:
+			return reflect.TypeOf([]string{})
+		case NumberModeInt64:
+			return typeAny
+		case NumberModeBigFloat:
+			return reflect.TypeOf([]*big.Float{})
+		}
+		return reflect.TypeOf([]float64{})
+	case "BS":
+		return typeBinarySet
+	case "L":
+		return typeL
+	case "M":
+		return typeM
+	case "N":
+		switch m {
+		case NumberModeJSON:
+			return reflect.TypeOf(json.Number(""))
+		case NumberModeString:
+			return typeS
+		case NumberModeInt64:
+			return typeAny
+		case NumberModeBigFloat:
+			return reflect.TypeOf(&big.Float{})
+		}
+		return typeN
+	}
+	return typeAny
+}
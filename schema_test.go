@@ -0,0 +1,180 @@
+package godynamo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestExtractSelectedTable(t *testing.T) {
+	testName := "TestExtractSelectedTable"
+	testData := map[string]string{
+		`SELECT * FROM "tbltest"`:                     "tbltest",
+		`SELECT * FROM "tbl.test"."idx" WHERE id=?`:   "tbl.test",
+		"SELECT a, b\nFROM tbl_test WHERE a='FROM x'": "tbl_test",
+		`SELECT "from" FROM "tbl-test" WITH Limit=10`: "tbl-test",
+		`SELECT *`: "",
+	}
+	for query, expected := range testData {
+		if table := extractSelectedTable(query); table != expected {
+			t.Fatalf("%s failed: expected %#v for %q but received %#v", testName, expected, query, table)
+		}
+	}
+}
+
+// _schemaStubServer returns a server that returns one item per page, each with the key attributes and one more
+// attribute named after the page number.
+func _schemaStubServer(numPages int) *stubDynamoDBServer {
+	return newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		page := 0
+		if token, ok := req["NextToken"].(string); ok {
+			_, _ = fmt.Sscanf(token, "page-%d", &page)
+		}
+		body := map[string]interface{}{
+			"Items": []interface{}{map[string]interface{}{
+				"user":                      map[string]interface{}{"S": "user"},
+				"app":                       map[string]interface{}{"S": "app"},
+				fmt.Sprintf("attr%d", page): map[string]interface{}{"N": fmt.Sprintf("%d", page)},
+			}},
+		}
+		if page+1 < numPages {
+			body["NextToken"] = fmt.Sprintf("page-%d", page+1)
+		}
+		return stubResponse{body: body}
+	})
+}
+
+func _queryColumns(db *sql.DB, query string) ([]string, []*sql.ColumnType, [][]interface{}, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	cols, _ := rows.Columns()
+	colTypes, _ := rows.ColumnTypes()
+	var data [][]interface{}
+	for rows.Next() {
+		row := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, nil, nil, err
+		}
+		data = append(data, row)
+	}
+	return cols, colTypes, data, rows.Err()
+}
+
+func TestResultResultSet_widenColumns(t *testing.T) {
+	testName := "TestResultResultSet_widenColumns"
+	server := _schemaStubServer(3)
+	defer server.Close()
+	testData := []struct {
+		name      string
+		extra     string
+		expected  []string
+		mustError bool
+	}{
+		{name: "first_page", extra: "", expected: []string{"app", "attr0", "user"}},
+		{name: "widen", extra: "WidenColumns=true", expected: []string{"app", "attr0", "attr1", "attr2", "user"}},
+		{name: "widen_max_items", extra: "WidenColumns=true;WidenColumnsMaxItems=3", expected: []string{"app", "attr0", "attr1", "attr2", "user"}},
+		{name: "widen_limit", extra: "WidenColumns=true;WidenColumnsMaxItems=2", mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			db, _ := sql.Open("godynamo", server.connStr(testCase.extra))
+			defer func() { _ = db.Close() }()
+			cols, _, data, err := _queryColumns(db, `SELECT * FROM "tbltest"`)
+			if testCase.mustError {
+				if !errors.Is(err, ErrWidenColumnsLimit) {
+					t.Fatalf("%s failed: expected ErrWidenColumnsLimit but received %v", testName+"/"+testCase.name, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if !reflect.DeepEqual(cols, testCase.expected) || len(data) != 3 {
+				t.Fatalf("%s failed: expected columns %#v and 3 rows but received %#v and %d rows", testName+"/"+testCase.name, testCase.expected, cols, len(data))
+			}
+		})
+	}
+}
+
+func TestResultResultSet_inferColumnTypes(t *testing.T) {
+	testName := "TestResultResultSet_inferColumnTypes"
+	server := newStubDynamoDBServer(func(op string, _ map[string]interface{}) stubResponse {
+		return stubResponse{body: map[string]interface{}{"Items": []interface{}{
+			map[string]interface{}{"mixed": map[string]interface{}{"S": "a"}, "nullable": map[string]interface{}{"NULL": true}, "num": map[string]interface{}{"N": "1"}},
+			map[string]interface{}{"mixed": map[string]interface{}{"N": "1"}, "nullable": map[string]interface{}{"S": "b"}, "num": map[string]interface{}{"N": "1.5"}},
+		}}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	testData := []struct {
+		query    string
+		expected []reflect.Type
+	}{
+		{query: `SELECT * FROM "tbltest"`, expected: []reflect.Type{typeAny, typeS, typeN}},
+		{query: `SELECT * FROM "tbltest" WITH number_mode=int64`, expected: []reflect.Type{typeAny, typeS, typeAny}},
+	}
+	for _, testCase := range testData {
+		cols, colTypes, _, err := _queryColumns(db, testCase.query)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		for i, colType := range colTypes {
+			if colType.ScanType() != testCase.expected[i] {
+				t.Fatalf("%s failed: expected scan type %s of column %s but received %s", testName, testCase.expected[i], cols[i], colType.ScanType())
+			}
+		}
+	}
+}
+
+func TestRegisterTableSchema(t *testing.T) {
+	testName := "TestRegisterTableSchema"
+	server := _schemaStubServer(2)
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("NumberMode=int64"))
+	defer func() { _ = db.Close() }()
+
+	RegisterTableSchema("tbltest", []Column{
+		{Name: "attr1", Type: "n"},
+		{Name: "user", Type: "S", KeyType: types.KeyTypeRange},
+		{Name: "other"},
+		{Name: "app", Type: "S", KeyType: types.KeyTypeHash},
+	})
+	defer DeregisterTableSchema("tbltest")
+
+	for _, query := range []string{`SELECT * FROM "tbltest"`, `SELECT * FROM "tbltest"."idx"`} {
+		cols, colTypes, data, err := _queryColumns(db, query)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if expected := []string{"app", "user", "attr1", "other"}; !reflect.DeepEqual(cols, expected) {
+			t.Fatalf("%s failed: expected columns %#v but received %#v", testName, expected, cols)
+		}
+		// numbers may be non-integral, which are returned as float64 in int64 mode
+		if colTypes[2].ScanType() != typeAny || colTypes[2].DatabaseTypeName() != "N" {
+			t.Fatalf("%s failed: unexpected type %s/%s of column attr1", testName, colTypes[2].ScanType(), colTypes[2].DatabaseTypeName())
+		}
+		expected := [][]interface{}{{"app", "user", nil, nil}, {"app", "user", int64(1), nil}}
+		if !reflect.DeepEqual(data, expected) {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, data)
+		}
+	}
+
+	// explicit column lists are kept as specified
+	cols, _, _, err := _queryColumns(db, `SELECT "user", attr0 FROM "tbltest"`)
+	if err != nil || !reflect.DeepEqual(cols, []string{"user", "attr0"}) {
+		t.Fatalf("%s failed: unexpected columns %#v (error %v)", testName, cols, err)
+	}
+}
@@ -0,0 +1,171 @@
+package godynamo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	typeStringSet = reflect.TypeOf(StringSet{})
+	typeBinarySet = reflect.TypeOf(BinarySet{})
+)
+
+// StringSet is a DynamoDB string set (SS). Passed as a parameter, a StringSet is written as a string set (a []string
+// is written as a list). String sets in result sets are returned as StringSet.
+//
+// @Available since v1.4.0
+type StringSet []string
+
+// Value implements driver.Valuer/Value.
+func (s StringSet) Value() (driver.Value, error) {
+	return types.AttributeValueMemberSS{Value: s}, nil
+}
+
+// Scan implements sql.Scanner/Scan.
+func (s *StringSet) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+	case StringSet:
+		*s = append(StringSet(nil), v...)
+	case []string:
+		*s = append(StringSet(nil), v...)
+	case []interface{}:
+		result := make(StringSet, len(v))
+		for i, e := range v {
+			str, ok := e.(string)
+			if !ok {
+				return fmt.Errorf("cannot scan %T into StringSet", e)
+			}
+			result[i] = str
+		}
+		*s = result
+	default:
+		return fmt.Errorf("cannot scan %T into StringSet", src)
+	}
+	return nil
+}
+
+/*----------------------------------------------------------------------*/
+
+// NumberSet is a DynamoDB number set (NS). Numbers are kept as json.Number so that no precision is lost. Passed as a
+// parameter, a NumberSet is written as a number set. Number sets in result sets are returned according to the number
+// mode (e.g. as []float64 by default) and can be scanned into a NumberSet; use NumberModeJSON or NumberModeString to
+// scan them without loss of precision.
+//
+// @Available since v1.4.0
+type NumberSet []json.Number
+
+// Value implements driver.Valuer/Value.
+func (s NumberSet) Value() (driver.Value, error) {
+	ns := make([]string, len(s))
+	for i, n := range s {
+		ns[i] = n.String()
+	}
+	return types.AttributeValueMemberNS{Value: ns}, nil
+}
+
+// Scan implements sql.Scanner/Scan.
+func (s *NumberSet) Scan(src interface{}) error {
+	if src == nil {
+		*s = nil
+		return nil
+	}
+	if v, ok := src.(NumberSet); ok {
+		*s = append(NumberSet(nil), v...)
+		return nil
+	}
+	rv := reflect.ValueOf(src)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return fmt.Errorf("cannot scan %T into NumberSet", src)
+	}
+	result := make(NumberSet, rv.Len())
+	for i := range result {
+		switch e := rv.Index(i).Interface().(type) {
+		case json.Number:
+			result[i] = e
+		case string:
+			if _, err := strconv.ParseFloat(e, 64); err != nil {
+				return fmt.Errorf("cannot scan %q into NumberSet: %w", e, err)
+			}
+			result[i] = json.Number(e)
+		case float64:
+			result[i] = json.Number(strconv.FormatFloat(e, 'g', -1, 64))
+		case int64:
+			result[i] = json.Number(strconv.FormatInt(e, 10))
+		case int:
+			result[i] = json.Number(strconv.Itoa(e))
+		case *big.Float:
+			result[i] = json.Number(e.Text('g', -1))
+		default:
+			return fmt.Errorf("cannot scan %T into NumberSet", e)
+		}
+	}
+	*s = result
+	return nil
+}
+
+// Float64s returns the numbers of the set as float64 values.
+func (s NumberSet) Float64s() ([]float64, error) {
+	result := make([]float64, len(s))
+	for i, n := range s {
+		var err error
+		if result[i], err = n.Float64(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Int64s returns the numbers of the set as int64 values. An error is returned if any number is not an integer.
+func (s NumberSet) Int64s() ([]int64, error) {
+	result := make([]int64, len(s))
+	for i, n := range s {
+		var err error
+		if result[i], err = n.Int64(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+/*----------------------------------------------------------------------*/
+
+// BinarySet is a DynamoDB binary set (BS). Passed as a parameter, a BinarySet is written as a binary set. Binary sets
+// in result sets are returned as BinarySet.
+//
+// @Available since v1.4.0
+type BinarySet [][]byte
+
+// Value implements driver.Valuer/Value.
+func (s BinarySet) Value() (driver.Value, error) {
+	return types.AttributeValueMemberBS{Value: s}, nil
+}
+
+// Scan implements sql.Scanner/Scan.
+func (s *BinarySet) Scan(src interface{}) error {
+	var bs [][]byte
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case BinarySet:
+		bs = v
+	case [][]byte:
+		bs = v
+	default:
+		return fmt.Errorf("cannot scan %T into BinarySet", src)
+	}
+	result := make(BinarySet, len(bs))
+	for i, b := range bs {
+		result[i] = append([]byte(nil), b...)
+	}
+	*s = result
+	return nil
+}
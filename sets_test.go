@@ -0,0 +1,169 @@
+package godynamo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestToAttributeValue_sets(t *testing.T) {
+	testName := "TestToAttributeValue_sets"
+	testData := []struct {
+		name     string
+		value    interface{}
+		expected types.AttributeValue
+	}{
+		{name: "string_set", value: StringSet{"a", "b"}, expected: &types.AttributeValueMemberSS{Value: []string{"a", "b"}}},
+		{name: "number_set", value: NumberSet{"1", "2.5"}, expected: &types.AttributeValueMemberNS{Value: []string{"1", "2.5"}}},
+		{name: "binary_set", value: BinarySet{[]byte("a")}, expected: &types.AttributeValueMemberBS{Value: [][]byte{[]byte("a")}}},
+		{name: "string_slice", value: []string{"a"}, expected: &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "a"}}}},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			av, err := ToAttributeValue(testCase.value)
+			if err != nil || !reflect.DeepEqual(av, testCase.expected) {
+				t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName+"/"+testCase.name, testCase.expected, av, err)
+			}
+		})
+	}
+}
+
+func TestSets_Scan(t *testing.T) {
+	testName := "TestSets_Scan"
+	testData := []struct {
+		name      string
+		dest      interface{ Scan(interface{}) error }
+		src       interface{}
+		expected  interface{}
+		mustError bool
+	}{
+		{name: "string_set", dest: &StringSet{}, src: StringSet{"a"}, expected: &StringSet{"a"}},
+		{name: "string_slice", dest: &StringSet{}, src: []interface{}{"a", "b"}, expected: &StringSet{"a", "b"}},
+		{name: "string_nil", dest: &StringSet{"a"}, src: nil, expected: &StringSet{}},
+		{name: "string_invalid", dest: &StringSet{}, src: []interface{}{1}, mustError: true},
+		{name: "number_set", dest: &NumberSet{}, src: NumberSet{"1"}, expected: &NumberSet{"1"}},
+		{name: "number_floats", dest: &NumberSet{}, src: []float64{1.5, 2}, expected: &NumberSet{"1.5", "2"}},
+		{name: "number_mixed", dest: &NumberSet{}, src: []interface{}{int64(1), "2", big.NewFloat(3)}, expected: &NumberSet{"1", "2", "3"}},
+		{name: "number_invalid", dest: &NumberSet{}, src: []string{"x"}, mustError: true},
+		{name: "number_bytes", dest: &NumberSet{}, src: []byte("1"), mustError: true},
+		{name: "binary_set", dest: &BinarySet{}, src: BinarySet{[]byte("a")}, expected: &BinarySet{[]byte("a")}},
+		{name: "binary_invalid", dest: &BinarySet{}, src: "a", mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.dest.Scan(testCase.src)
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: expected error", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if reflect.ValueOf(testCase.dest).Elem().Len() == 0 && reflect.ValueOf(testCase.expected).Elem().Len() == 0 {
+				return
+			}
+			if !reflect.DeepEqual(testCase.dest, testCase.expected) {
+				t.Fatalf("%s failed: expected %#v but received %#v", testName+"/"+testCase.name, testCase.expected, testCase.dest)
+			}
+		})
+	}
+}
+
+func TestNumberSet_conversions(t *testing.T) {
+	testName := "TestNumberSet_conversions"
+	if floats, err := (NumberSet{"1.5", "2"}).Float64s(); err != nil || !reflect.DeepEqual(floats, []float64{1.5, 2}) {
+		t.Fatalf("%s failed: unexpected %#v (error %v)", testName, floats, err)
+	}
+	if ints, err := (NumberSet{"9007199254740993", "2"}).Int64s(); err != nil || !reflect.DeepEqual(ints, []int64{9007199254740993, 2}) {
+		t.Fatalf("%s failed: unexpected %#v (error %v)", testName, ints, err)
+	}
+	if _, err := (NumberSet{"1.5"}).Int64s(); err == nil {
+		t.Fatalf("%s failed: expected error for non-integral number", testName)
+	}
+}
+
+// _itemStubServer returns a server that stores the parameters of INSERT statements as an item, and returns the
+// stored items for SELECT statements.
+func _itemStubServer() *stubDynamoDBServer {
+	var lock sync.Mutex
+	var items []interface{}
+	return newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		lock.Lock()
+		defer lock.Unlock()
+		statement, _ := req["Statement"].(string)
+		if strings.HasPrefix(statement, "INSERT") {
+			params, _ := req["Parameters"].([]interface{})
+			item := map[string]interface{}{}
+			for i, name := range []string{"id", "ss", "ns", "bs"} {
+				item[name] = params[i]
+			}
+			items = append(items, item)
+			return stubResponse{}
+		}
+		return stubResponse{body: map[string]interface{}{"Items": items}}
+	})
+}
+
+func TestSets_roundTrip(t *testing.T) {
+	testName := "TestSets_roundTrip"
+	server := _itemStubServer()
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("NumberMode=json"))
+	defer func() { _ = db.Close() }()
+
+	ss, ns, bs := StringSet{"a", "b"}, NumberSet{"9007199254740993", "1.5"}, BinarySet{[]byte("x"), []byte("y")}
+	if _, err := db.Exec(`INSERT INTO "tbltest" VALUE {'id': ?, 'ss': ?, 'ns': ?, 'bs': ?}`, "1", ss, ns, bs); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	rows, err := db.Query(`SELECT * FROM "tbltest"`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = rows.Close() }()
+	colTypes, _ := rows.ColumnTypes()
+	expectedTypes := map[string]reflect.Type{"bs": typeBinarySet, "id": typeS, "ns": reflect.TypeOf([]json.Number{}), "ss": typeStringSet}
+	expectedDbTypes := map[string]string{"bs": "BS", "id": "S", "ns": "NS", "ss": "SS"}
+	for _, colType := range colTypes {
+		if colType.ScanType() != expectedTypes[colType.Name()] || colType.DatabaseTypeName() != expectedDbTypes[colType.Name()] {
+			t.Fatalf("%s failed: unexpected type %s/%s of column %s", testName, colType.ScanType(), colType.DatabaseTypeName(), colType.Name())
+		}
+	}
+	if !rows.Next() {
+		t.Fatalf("%s failed: expected one row (error %v)", testName, rows.Err())
+	}
+	var id string
+	var ssOut StringSet
+	var nsOut NumberSet
+	var bsOut BinarySet
+	if err = rows.Scan(&bsOut, &id, &nsOut, &ssOut); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if id != "1" || !reflect.DeepEqual(ssOut, ss) || !reflect.DeepEqual(nsOut, ns) || !reflect.DeepEqual(bsOut, bs) {
+		t.Fatalf("%s failed: expected %#v/%#v/%#v but received %#v/%#v/%#v", testName, ss, ns, bs, ssOut, nsOut, bsOut)
+	}
+
+	var anyValue interface{}
+	if err = db.QueryRow(`SELECT ns FROM "tbltest"`).Scan(&anyValue); err != nil || !reflect.DeepEqual(anyValue, []json.Number(ns)) {
+		t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, ns, anyValue, err)
+	}
+
+	// number sets are returned according to the number mode, e.g. []float64 by default
+	db64, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db64.Close() }()
+	var floats []float64
+	if err = db64.QueryRow(`SELECT ns FROM "tbltest"`).Scan(&floats); err != nil || !reflect.DeepEqual(floats, []float64{9007199254740992, 1.5}) {
+		t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, []float64{9007199254740992, 1.5}, floats, err)
+	}
+	var nsFloats NumberSet
+	if err = db64.QueryRow(`SELECT ns FROM "tbltest"`).Scan(&nsFloats); err != nil || !reflect.DeepEqual(nsFloats, NumberSet{"9.007199254740992e+15", "1.5"}) {
+		t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, NumberSet{"9.007199254740992e+15", "1.5"}, nsFloats, err)
+	}
+}
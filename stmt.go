@@ -1,16 +1,21 @@
 package godynamo
 
 import (
+	"context"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/btnguyen2k/consu/reddo"
 )
@@ -19,7 +24,7 @@ const (
 	field       = `([\w\-]+)`
 	ifNotExists = `(\s+IF\s+NOT\s+EXISTS)?`
 	ifExists    = `(\s+IF\s+EXISTS)?`
-	with        = `(\s+WITH\s+` + field + `\s*=\s*([\w/\.\*,;:'"-]+)((\s+|\s*,\s+|\s+,\s*)WITH\s+` + field + `\s*=\s*([\w/\.\*,;:'"-]+))*)?`
+	with        = `(\s+WITH\s+` + field + `\s*=\s*([\w/\.\*,;:'"?-]+)((\s+|\s*,\s+|\s+,\s*)WITH\s+` + field + `\s*=\s*([\w/\.\*,;:'"?-]+))*)?`
 )
 
 var (
@@ -39,6 +44,9 @@ var (
 	reSelect = regexp.MustCompile(`(?im)^SELECT\s+.*?` + with + `$`)
 	reUpdate = regexp.MustCompile(`(?im)^UPDATE\s+`)
 	reDelete = regexp.MustCompile(`(?im)^DELETE\s+FROM\s+`)
+	reUpsert = regexp.MustCompile(`(?is)^(UPSERT|REPLACE)\s+INTO\s+`)
+	reQuery  = regexp.MustCompile(`(?is)^QUERY\s+.*?` + with + `$`)
+	reScan   = regexp.MustCompile(`(?is)^SCAN\s+.*?` + with + `$`)
 )
 
 func parseQuery(c *Conn, query string) (driver.Stmt, error) {
@@ -158,6 +166,15 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		}
 		return stmt, stmt.validate()
 	}
+	if re := reUpsert; re.MatchString(query) {
+		stmt := &StmtUpsert{
+			Stmt: &Stmt{query: query, conn: c, numInput: 0},
+		}
+		if err := stmt.parse(); err != nil {
+			return nil, err
+		}
+		return stmt, stmt.validate()
+	}
 	if re := reSelect; re.MatchString(query) {
 		groups := re.FindAllStringSubmatch(query, -1)
 		withOptsStr := groups[0][1]
@@ -189,6 +206,30 @@ func parseQuery(c *Conn, query string) (driver.Stmt, error) {
 		}
 		return stmt, stmt.validate()
 	}
+	if re := reQuery; re.MatchString(query) {
+		groups := re.FindAllStringSubmatch(query, -1)
+		withOptsStr := groups[0][1]
+		stmt := &StmtQuery{stmtNative: &stmtNative{
+			Stmt:        &Stmt{query: query[0 : len(query)-len(withOptsStr)], conn: c, numInput: 0},
+			withOptsStr: " " + strings.TrimSpace(withOptsStr),
+		}}
+		if err := stmt.parse(); err != nil {
+			return nil, err
+		}
+		return stmt, stmt.validate()
+	}
+	if re := reScan; re.MatchString(query) {
+		groups := re.FindAllStringSubmatch(query, -1)
+		withOptsStr := groups[0][1]
+		stmt := &StmtScan{stmtNative: &stmtNative{
+			Stmt:        &Stmt{query: query[0 : len(query)-len(withOptsStr)], conn: c, numInput: 0},
+			withOptsStr: " " + strings.TrimSpace(withOptsStr),
+		}}
+		if err := stmt.parse(); err != nil {
+			return nil, err
+		}
+		return stmt, stmt.validate()
+	}
 
 	return nil, fmt.Errorf("invalid query: %s", query)
 }
@@ -217,14 +258,15 @@ func (s OptStrings) FirstBool() bool {
 
 // Stmt is AWS DynamoDB abstract implementation of driver.Stmt.
 type Stmt struct {
-	query    string // the SQL query
-	conn     *Conn  // the connection that this prepared statement is bound to
-	numInput int    // number of placeholder parameters
-	limit    *int32 // limit for SELECT statement
-	withOpts map[string]OptStrings
+	query         string // the SQL query
+	conn          *Conn  // the connection that this prepared statement is bound to
+	numInput      int    // number of placeholder parameters
+	limit         *int32 // limit for SELECT statement
+	withOpts      map[string]OptStrings
+	autoReturning bool // if true, "RETURNING ALL OLD *" is appended to the query when executed outside transactions
 }
 
-var reWithOpts = regexp.MustCompile(`(?im)^(\s+|\s*,\s+|\s+,\s*)WITH\s+` + field + `\s*=\s*([\w/\.\*,;:'"-]+)`)
+var reWithOpts = regexp.MustCompile(`(?im)^(\s+|\s*,\s+|\s+,\s*)WITH\s+` + field + `\s*=\s*([\w/\.\*,;:'"?-]+)`)
 
 // parseWithOpts parses "WITH..." clause and store result in withOpts map.
 // This function returns no error. Sub-implementations may override this behavior.
@@ -242,6 +284,44 @@ func (s *Stmt) parseWithOpts(withOptsStr string) error {
 	return nil
 }
 
+// numberMode returns the number mode of the statement's result sets: the one specified via "WITH number_mode=..."
+// if any, otherwise the connection's.
+func (s *Stmt) numberMode() NumberMode {
+	for _, k := range []string{"NUMBER_MODE", "NUMBERMODE"} {
+		if len(s.withOpts[k]) > 0 {
+			mode, _ := ParseNumberMode(s.withOpts[k].FirstString())
+			return mode
+		}
+	}
+	if s.conn != nil {
+		return s.conn.numberMode
+	}
+	return ""
+}
+
+// prefetch returns the number of pages of the statement's result sets fetched ahead: the one specified via
+// "WITH Prefetch=..." if any, otherwise the connection's.
+func (s *Stmt) prefetch() int {
+	if len(s.withOpts["PREFETCH"]) > 0 {
+		prefetch, _ := strconv.Atoi(s.withOpts["PREFETCH"].FirstString())
+		return prefetch
+	}
+	if s.conn != nil {
+		return s.conn.prefetch
+	}
+	return 0
+}
+
+// render returns the query to send to DynamoDB. The query is rendered when the statement is executed, not when it is
+// prepared, so that a statement prepared outside a transaction can be used in one (e.g. via sql.Tx.Stmt), and vice
+// versa.
+func (s *Stmt) render(inTx bool) string {
+	if s.autoReturning && !inTx {
+		return s.query + " RETURNING ALL OLD *"
+	}
+	return s.query
+}
+
 // Close implements driver.Stmt/Close.
 func (s *Stmt) Close() error {
 	return nil
@@ -254,8 +334,9 @@ func (s *Stmt) NumInput() int {
 
 // ResultNoResultSet captures the result from statements that do not expect a ResultSet to be returned.
 type ResultNoResultSet struct {
-	err          error
-	affectedRows int64
+	err              error
+	affectedRows     int64
+	consumedCapacity *types.ConsumedCapacity
 }
 
 // LastInsertId implements driver.Result/LastInsertId.
@@ -268,16 +349,48 @@ func (r *ResultNoResultSet) RowsAffected() (int64, error) {
 	return r.affectedRows, r.err
 }
 
+// ConsumedCapacity implements CapacityReporter/ConsumedCapacity.
+//
+// @Available since v1.4.0
+func (r *ResultNoResultSet) ConsumedCapacity() (*types.ConsumedCapacity, error) {
+	return r.consumedCapacity, r.err
+}
+
 // ResultResultSet captures the result from statements that expect a ResultSet to be returned.
 type ResultResultSet struct {
 	err               error
 	columnList        []string
 	columnTypes       map[string]reflect.Type
 	columnSourceTypes map[string]string
-	mu                sync.Mutex // protects the following fields
+	inferredTypes     map[string]bool // columns whose types are inferred from the returned items, not registered
+	schema            []Column        // registered schema of the selected table, if any
+	mu                sync.Mutex      // protects the following fields
 	stmt              *statement
+	ctx               context.Context    // context used to fetch subsequent pages
+	cancel            context.CancelFunc // releases ctx, called on Close
+	capacityLock      sync.Mutex         // protects consumedCapacity, which is also updated by the prefetch goroutine
+	consumedCapacity  *types.ConsumedCapacity
 	read              int32
-	items             []map[string]types.AttributeValue
+	items             []map[string]types.AttributeValue // items of the current page not yet read
+	pageToken         *string                           // NextToken used to fetch the current page, nil for the first page
+	pageOffset        int                               // number of items of the current page already read (or skipped)
+	skip              int                               // number of items to skip from the first page, when resuming
+	pending           []resultPage                      // pages fetched ahead of the current page
+	prefetched        chan prefetchedPage               // pages fetched ahead in background, nil if prefetch is off
+	stopPrefetch      context.CancelFunc                // stops the prefetch goroutine
+	prefetchDone      chan struct{}                     // closed when the prefetch goroutine exits
+}
+
+// resultPage is a page of a result set fetched ahead.
+type resultPage struct {
+	token  *string // NextToken used to fetch the page
+	output *dynamodb.ExecuteStatementOutput
+}
+
+// prefetchedPage is a page, or the error fetching it, sent by the prefetch goroutine.
+type prefetchedPage struct {
+	page resultPage
+	err  error
 }
 
 func (r *ResultResultSet) init() *ResultResultSet {
@@ -294,19 +407,91 @@ func (r *ResultResultSet) init() *ResultResultSet {
 		return r
 	}
 
+	r.ctx = r.stmt.ctx
+	if r.ctx == nil {
+		r.ctx = context.Background()
+	}
+	if r.stmt.resultSetTimeout > 0 {
+		r.ctx, r.cancel = context.WithDeadline(r.ctx, r.stmt.started.Add(r.stmt.resultSetTimeout))
+	}
 	r.items = r.stmt.output.Items
+	if r.stmt.input != nil {
+		r.pageToken = r.stmt.input.NextToken
+	}
+	if r.skip > 0 {
+		r.pageOffset = min(r.skip, len(r.items))
+		r.items = r.items[r.pageOffset:]
+	}
+	r.addConsumedCapacity(r.stmt.output.ConsumedCapacity)
+	if r.stmt.native() {
+		// wait for the first non-empty page (of any segment)
+		r.startNative()
+		for len(r.items) == 0 {
+			if err := r.fetchNext(); err != nil {
+				if err != io.EOF {
+					r.err = err
+				}
+				break
+			}
+		}
+	}
+
+	if len(r.schema) > 0 {
+		if len(r.columnList) == 0 {
+			r.columnList = make([]string, len(r.schema))
+			for i, col := range r.schema {
+				r.columnList[i] = col.Name
+			}
+		}
+		for _, col := range r.schema {
+			if col.Type != "" {
+				r.columnTypes[col.Name] = r.stmt.numberMode.scanType(col.Type)
+				r.columnSourceTypes[col.Name] = col.Type
+			}
+		}
+	} else if r.stmt.widenColumns && len(r.columnList) == 0 && r.stmt.native() {
+		// wait for all pages so that the column list covers the attributes of all items
+		numItems := len(r.items)
+		for page := range r.prefetched {
+			if page.err != nil {
+				r.err = page.err
+				break
+			}
+			r.pending = append(r.pending, page.page)
+			if numItems += len(page.page.output.Items); r.checkWidenLimit(numItems) != nil {
+				break
+			}
+		}
+	} else if r.stmt.widenColumns && len(r.columnList) == 0 {
+		// fetch all pages so that the column list covers the attributes of all items
+		for numItems, token := len(r.items), r.stmt.output.NextToken; token != nil && (r.stmt.limit <= 0 || numItems < int(r.stmt.limit)); {
+			page, err := r.fetchPage(r.ctx, token)
+			if err != nil {
+				r.err = err
+				break
+			}
+			r.pending = append(r.pending, page)
+			if numItems += len(page.output.Items); r.checkWidenLimit(numItems) != nil {
+				break
+			}
+			token = page.output.NextToken
+		}
+	}
+	if r.stmt.prefetch > 0 && len(r.pending) == 0 && r.err == nil && r.stmt.output.NextToken != nil {
+		r.startPrefetch()
+	}
 
 	// pre-calculate column types
 	colMap := make(map[string]bool)
-	for _, item := range r.stmt.output.Items {
+	pageItems := [][]map[string]types.AttributeValue{r.items}
+	for _, page := range r.pending {
+		pageItems = append(pageItems, page.output.Items)
+	}
+	r.inferredTypes = make(map[string]bool)
+	for _, item := range slices.Concat(pageItems...) {
 		for col, av := range item {
 			colMap[col] = true
-			if r.columnTypes[col] == nil {
-				var value interface{}
-				_ = attributevalue.Unmarshal(av, &value)
-				r.columnTypes[col] = reflect.TypeOf(value)
-				r.columnSourceTypes[col] = nameFromAttributeValue(av)
-			}
+			r.inferColumnType(col, av)
 		}
 	}
 
@@ -325,16 +510,119 @@ func (r *ResultResultSet) init() *ResultResultSet {
 	return r
 }
 
-func (r *ResultResultSet) fetchNext() (err error) {
-	if r.stmt.output.NextToken == nil {
-		return io.EOF
+// checkWidenLimit sets the error of the result set if numItems, the number of items fetched to widen its columns,
+// exceeds the limit.
+func (r *ResultResultSet) checkWidenLimit(numItems int) error {
+	if r.stmt.widenMaxItems > 0 && numItems > r.stmt.widenMaxItems && (r.stmt.limit <= 0 || int(r.stmt.limit) > r.stmt.widenMaxItems) {
+		r.err = fmt.Errorf("%w: more than %d items, register the schema of the table instead", ErrWidenColumnsLimit, r.stmt.widenMaxItems)
+		r.pending = nil
 	}
-	r.stmt.input.NextToken = r.stmt.output.NextToken
-	r.stmt.output, err = r.stmt.client.ExecuteStatement(r.stmt.ctx, r.stmt.input)
-	if err == nil && r.stmt.output != nil {
-		r.items = r.stmt.output.Items
+	return r.err
+}
+
+// inferColumnType infers the type of a column not registered with a type from av, one of its values: the type of
+// its DynamoDB data type, or interface{} if its values have different data types. NULL values are not taken into
+// account, unless the column has no other value.
+func (r *ResultResultSet) inferColumnType(col string, av types.AttributeValue) {
+	if r.columnTypes[col] != nil && !r.inferredTypes[col] {
+		return
+	}
+	dataType := nameFromAttributeValue(av)
+	switch prev := r.columnSourceTypes[col]; {
+	case !r.inferredTypes[col] || prev == "NULL":
+		r.inferredTypes[col] = true
+		r.columnTypes[col] = r.stmt.numberMode.scanType(dataType)
+		r.columnSourceTypes[col] = dataType
+	case dataType != "NULL" && dataType != prev:
+		r.columnTypes[col] = typeAny
+	}
+}
+
+func (r *ResultResultSet) addConsumedCapacity(capacity *types.ConsumedCapacity) {
+	r.capacityLock.Lock()
+	defer r.capacityLock.Unlock()
+	r.consumedCapacity = addConsumedCapacity(r.consumedCapacity, capacity)
+}
+
+// fetchPage fetches the page of the result set starting at token.
+func (r *ResultResultSet) fetchPage(ctx context.Context, token *string) (resultPage, error) {
+	input := *r.stmt.input
+	input.NextToken = token
+	ctx, cancel := withTimeout(ctx, r.stmt.timeout)
+	defer cancel()
+	output, err := r.stmt.client.ExecuteStatement(ctx, &input)
+	if err != nil {
+		return resultPage{}, err
 	}
-	return err
+	r.addConsumedCapacity(output.ConsumedCapacity)
+	capacityCollectorFromContext(r.ctx).add(output.ConsumedCapacity)
+	return resultPage{token: token, output: output}, nil
+}
+
+// startPrefetch starts a goroutine fetching up to stmt.prefetch pages ahead of the current page. The goroutine stops
+// once all pages (or enough items to reach the limit) are fetched, on error, or when stopPrefetch is called.
+func (r *ResultResultSet) startPrefetch() {
+	var ctx context.Context
+	ctx, r.stopPrefetch = context.WithCancel(r.ctx)
+	// the page blocked on sending counts as fetched ahead
+	r.prefetched = make(chan prefetchedPage, r.stmt.prefetch-1)
+	r.prefetchDone = make(chan struct{})
+	go func(token *string, numItems int) {
+		defer close(r.prefetchDone)
+		defer close(r.prefetched)
+		for token != nil && (r.stmt.limit <= 0 || numItems < int(r.stmt.limit)) {
+			page, err := r.fetchPage(ctx, token)
+			select {
+			case r.prefetched <- prefetchedPage{page: page, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+			numItems += len(page.output.Items)
+			token = page.output.NextToken
+		}
+	}(r.stmt.output.NextToken, len(r.items))
+}
+
+// fetchNext makes the next page, either fetched ahead or fetched now, the current page. It returns io.EOF if there
+// is no more page.
+func (r *ResultResultSet) fetchNext() error {
+	var page resultPage
+	switch {
+	case len(r.pending) > 0:
+		page, r.pending = r.pending[0], r.pending[1:]
+	case r.prefetched != nil:
+		select {
+		case p, ok := <-r.prefetched:
+			if !ok {
+				if err := r.ctx.Err(); err != nil {
+					return err
+				}
+				return io.EOF
+			}
+			if p.err != nil {
+				return p.err
+			}
+			page = p.page
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		}
+	default:
+		if r.stmt.output.NextToken == nil {
+			return io.EOF
+		}
+		var err error
+		if page, err = r.fetchPage(r.ctx, r.stmt.output.NextToken); err != nil {
+			return err
+		}
+	}
+	r.stmt.output = page.output
+	r.items = page.output.Items
+	r.pageToken = page.token
+	r.pageOffset = 0
+	return nil
 }
 
 // Columns implements driver.Rows/Columns.
@@ -343,6 +631,11 @@ func (r *ResultResultSet) Columns() []string {
 }
 
 // ColumnTypeScanType implements driver.RowsColumnTypeScanType/ColumnTypeScanType
+//
+// @Since v1.4.0 the scan type is the type values are returned as: the type of the registered data type of the column
+// (see RegisterTableSchema), otherwise the type of the data type of the column's values in the loaded items, or
+// interface{} if they have different data types (or, with NumberModeInt64, are numbers). As items not loaded yet may
+// hold values of other data types, register the schema of the table for guaranteed scan types.
 func (r *ResultResultSet) ColumnTypeScanType(index int) reflect.Type {
 	return r.columnTypes[r.columnList[index]]
 }
@@ -355,10 +648,76 @@ func (r *ResultResultSet) ColumnTypeDatabaseTypeName(index int) string {
 }
 
 // Close implements driver.Rows/Close.
+//
+// @Since v1.4.0 the resume token is reported to the ResumeTokenCollector attached to the statement's context, if any.
+//
+// @Since v1.4.0 the prefetch goroutine, if any, is stopped.
 func (r *ResultResultSet) Close() error {
+	if r.stopPrefetch != nil {
+		r.stopPrefetch()
+		<-r.prefetchDone
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+	if collector := resumeTokenCollectorFromContext(r.ctx); collector != nil {
+		token, _ := r.ResumeToken()
+		collector.set(token)
+	}
 	return r.err
 }
 
+// ResumeToken returns an opaque token of the position of the result set, which can be passed to a SELECT statement
+// via "WITH PageToken=?" to continue reading from the next row. An empty string is returned if there is no more row.
+//
+// The token is signed if Config.PageTokenSecret is set. The same statement (with the same parameters) must be used
+// to resume.
+//
+// @Available since v1.4.0
+func (r *ResultResultSet) ResumeToken() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stmt == nil || r.stmt.output == nil {
+		return "", r.err
+	}
+	if r.stmt.scanInput != nil {
+		return "", fmt.Errorf("%w: resume token", ErrUnsupportedScan)
+	}
+	if r.stmt.queryInput != nil {
+		return "", errors.New("resume token is not supported by QUERY statements")
+	}
+	token := pageToken{NextToken: aws.ToString(r.pageToken), Offset: r.pageOffset}
+	if len(r.items) == 0 {
+		// the current page has been read, resume from the next one
+		next := r.stmt.output.NextToken
+		if len(r.pending) > 0 {
+			next = r.pending[0].token
+		}
+		if next == nil {
+			return "", nil
+		}
+		token = pageToken{NextToken: *next}
+	}
+	return token.encode(r.stmt.pageTokenSecret), nil
+}
+
+// ConsumedCapacity implements CapacityReporter/ConsumedCapacity.
+//
+// The returned value is the sum of the capacity consumed by all pages fetched so far.
+//
+// @Available since v1.4.0
+func (r *ResultResultSet) ConsumedCapacity() (*types.ConsumedCapacity, error) {
+	r.capacityLock.Lock()
+	capacity := r.consumedCapacity
+	r.capacityLock.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil && r.err != io.EOF {
+		return capacity, r.err
+	}
+	return capacity, nil
+}
+
 // Next implements driver.Rows/Next.
 func (r *ResultResultSet) Next(dest []driver.Value) error {
 	if r.err != nil {
@@ -380,12 +739,12 @@ func (r *ResultResultSet) Next(dest []driver.Value) error {
 	}
 	rowData := r.items[0]
 	r.items = r.items[1:]
+	r.pageOffset++
 	r.mu.Unlock()
 	r.read++
 
 	for i, colName := range r.columnList {
-		var value interface{}
-		_ = attributevalue.Unmarshal(rowData[colName], &value)
+		value, _ := r.stmt.numberMode.unmarshal(rowData[colName])
 		dest[i] = value
 	}
 	return nil
@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 var (
@@ -17,6 +19,7 @@ var (
 	reReturning    = regexp.MustCompile(`(?im)\s+RETURNING\s+((ALL\s+OLD)|(MODIFIED\s+OLD)|(ALL\s+NEW)|(MODIFIED\s+NEW))\s+\*\s*$`)
 	reLimit        = regexp.MustCompile(`(?im)\s+LIMIT\s+(\S+)\s*`)
 	reSelectedList = regexp.MustCompile(`(?im)SELECT\s+(.*)\s+FROM\s+`)
+	reSelectTable  = regexp.MustCompile(`(?is)\s+FROM\s+(?:"([^"]+)"|([\w\-]+))`)
 )
 
 /*----------------------------------------------------------------------*/
@@ -24,6 +27,7 @@ var (
 // StmtExecutable is the base implementation for INSERT, SELECT, UPDATE and DELETE statements.
 type StmtExecutable struct {
 	*Stmt
+	onConditionFail string // OnConditionFailError or OnConditionFailIgnore, for INSERT, UPDATE and DELETE statements
 }
 
 var (
@@ -48,10 +52,20 @@ func (s *StmtExecutable) validate() error {
 // StmtInsert implements "INSERT" statement.
 //
 // Syntax: follow "PartiQL insert statements for DynamoDB" https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ql-reference.insert.html
+//
+// @Since v1.4.0 a failed condition check (e.g. the item already exists) is returned as a *ConditionFailedError. The
+// statement can end with "WITH OnConditionFail=ignore" to succeed with no affected row instead.
 type StmtInsert struct {
 	*StmtExecutable
 }
 
+func (s *StmtInsert) parse() error {
+	if err := s.parseOnConditionFail(OnConditionFailError); err != nil {
+		return err
+	}
+	return s.StmtExecutable.parse()
+}
+
 // Query implements driver.Stmt/Query.
 // This function is not implemented, use Exec instead.
 func (s *StmtInsert) Query(_ []driver.Value) (driver.Rows, error) {
@@ -66,14 +80,14 @@ func (s *StmtInsert) QueryContext(_ context.Context, _ []driver.NamedValue) (dri
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtInsert) Exec(values []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
 func (s *StmtInsert) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
-	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values, s.conditionOptFns()...)
 	if errors.Is(err, ErrInTx) {
 		return &TxResultNoResultSet{outputFn: outputFn}, nil
 	}
@@ -81,7 +95,153 @@ func (s *StmtInsert) ExecContext(ctx context.Context, values []driver.NamedValue
 	if err == nil {
 		affectedRows = 1
 	}
-	return &ResultNoResultSet{err: err, affectedRows: affectedRows}, err
+	err = s.checkCondition(err)
+	return &ResultNoResultSet{err: err, affectedRows: affectedRows, consumedCapacity: outputFn.consumedCapacity()}, err
+}
+
+/*----------------------------------------------------------------------*/
+
+// StmtUpsert implements "UPSERT" and "REPLACE" statements, which are executed with the native PutItem API: the item
+// is created, or replaced entirely if it already exists.
+//
+// Syntax:
+//
+//	UPSERT|REPLACE INTO <table> VALUE <document> [RETURNING ALL OLD *]
+//
+// The document follows the PartiQL syntax of INSERT statements, e.g. {'id': ?, 'tags': <<'a', 'b'>>, 'n': 1}, and
+// can contain placeholders. With RETURNING ALL OLD *, Query returns the replaced item, if any.
+//
+// In a transaction, the statement is executed as a Put of TransactWriteItems. DynamoDB executes PartiQL statements
+// (ExecuteTransaction) and TransactWriteItems separately, and the driver does not translate PartiQL statements into
+// TransactWriteItems: a transaction holds either UPSERT/REPLACE statements only, or INSERT/UPDATE/DELETE statements
+// only, otherwise the statement is refused with ErrTxMixedStatements.
+//
+// @Available since v1.4.0
+type StmtUpsert struct {
+	*Stmt
+	tableName string
+	item      docMap
+	returnOld bool
+}
+
+func (s *StmtUpsert) parse() error {
+	p, err := newDocParser(s.query)
+	if err != nil {
+		return err
+	}
+	if !p.accept("UPSERT") && !p.accept("REPLACE") {
+		return p.unexpected("expected UPSERT or REPLACE")
+	}
+	if err = p.expect("INTO"); err != nil {
+		return err
+	}
+	switch token := p.peek(); {
+	case strings.HasPrefix(token, `"`):
+		s.tableName = token[1 : len(token)-1]
+	case token != "" && (token[0] == '_' || token[0] >= 'A' && token[0] <= 'Z' || token[0] >= 'a' && token[0] <= 'z'):
+		s.tableName = token
+	default:
+		return p.unexpected("expected a table name")
+	}
+	p.pos++
+	if err = p.expect("VALUE"); err != nil {
+		return err
+	}
+	if p.peek() != "{" {
+		return p.unexpected("expected a document")
+	}
+	item, err := p.value()
+	if err != nil {
+		return err
+	}
+	s.item = item.(docMap)
+	if p.accept("RETURNING") {
+		for _, keyword := range []string{"ALL", "OLD", "*"} {
+			if err = p.expect(keyword); err != nil {
+				return fmt.Errorf("only RETURNING ALL OLD * is supported: %w", err)
+			}
+		}
+		s.returnOld = true
+	}
+	if p.pos < len(p.tokens) {
+		return p.unexpected("expected end of statement")
+	}
+	s.numInput = p.numParams
+	return nil
+}
+
+func (s *StmtUpsert) validate() error {
+	return nil
+}
+
+// putItemInput builds the PutItem request of the statement. The replaced item is requested if returnOld is true.
+func (s *StmtUpsert) putItemInput(values []driver.NamedValue, returnOld bool) (*dynamodb.PutItemInput, error) {
+	params := make([]types.AttributeValue, len(values))
+	for i, v := range values {
+		var err error
+		if params[i], err = ToAttributeValue(v.Value); err != nil {
+			return nil, fmt.Errorf("error marshalling parameter %d-th: %s", i+1, err)
+		}
+	}
+	item, err := bindDoc(s.item, params)
+	if err != nil {
+		return nil, err
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:              aws.String(s.tableName),
+		Item:                   item.(*types.AttributeValueMemberM).Value,
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	if returnOld {
+		input.ReturnValues = types.ReturnValueAllOld
+	}
+	return input, nil
+}
+
+// Query implements driver.Stmt/Query.
+func (s *StmtUpsert) Query(values []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
+}
+
+// QueryContext implements driver.StmtQueryContext/QueryContext.
+//
+// The result set holds the replaced item if the statement has RETURNING ALL OLD *, and is empty otherwise. Query is
+// not supported in transactions, use Exec.
+func (s *StmtUpsert) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
+	if s.conn.txMode == txStarted {
+		return nil, errQueryInTx
+	}
+	input, err := s.putItemInput(values, s.returnOld)
+	if err != nil {
+		return nil, err
+	}
+	outputFn, err := s.conn.executePut(ctx, s.Stmt, input)
+	if err != nil {
+		return nil, err
+	}
+	return (&ResultResultSet{stmt: outputFn()}).init(), nil
+}
+
+// Exec implements driver.Stmt/Exec.
+func (s *StmtUpsert) Exec(values []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
+}
+
+// ExecContext implements driver.StmtExecContext/ExecContext.
+func (s *StmtUpsert) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
+	input, err := s.putItemInput(values, false)
+	if err != nil {
+		return nil, err
+	}
+	outputFn, err := s.conn.executePut(ctx, s.Stmt, input)
+	if errors.Is(err, ErrInTx) {
+		return &TxResultNoResultSet{outputFn: outputFn}, nil
+	}
+	affectedRows := int64(0)
+	if err == nil {
+		affectedRows = 1
+	}
+	return &ResultNoResultSet{err: err, affectedRows: affectedRows, consumedCapacity: outputFn.consumedCapacity()}, err
 }
 
 /*----------------------------------------------------------------------*/
@@ -93,11 +253,41 @@ func (s *StmtInsert) ExecContext(ctx context.Context, values []driver.NamedValue
 // @Since v0.3.0 support LIMIT clause
 //
 // @Since v0.4.0 support WITH consistency=strong clause
+//
+// @Since v1.4.0 support WITH number_mode=<mode> clause, see NumberMode
+//
+// @Since v1.4.0 support WITH PageToken=<token> (or NextToken=<token>) clause to resume reading a result set from a
+// token returned by ResultResultSet.ResumeToken, and WITH PageSize=<n> clause to set the maximum number of items
+// DynamoDB evaluates per page (independently of LIMIT). Their values can be placeholders (e.g. "WITH PageToken=?"),
+// bound to the last parameters of the statement.
+//
+// @Since v1.4.0 support WITH Prefetch=<n> clause to fetch up to n pages ahead in background, see Config.Prefetch
+//
+// @Since v1.4.0 support WITH Segments=<n> clause to execute the statement as a parallel Scan of n segments, merging
+// the items of all segments into a single result set (in no particular order). Up to 16 segments are scanned
+// concurrently; the remaining segments are scanned as soon as one completes. Only statements of the form
+// "SELECT * | attr[, attr...] FROM table[.index] [WHERE condition]" are supported, where condition combines with
+// AND, OR, NOT and parentheses: comparisons (=, <>, <, <=, >, >=), BETWEEN, IN, IS [NOT] MISSING and the functions
+// begins_with, contains, attribute_type, EXISTS and MISSING. Other statements are rejected with ErrUnsupportedScan.
+// LIMIT, ConsistentRead and PageSize (the maximum number of items evaluated per Scan call) are supported; PageToken
+// and resume tokens are not.
+//
+// @Since v1.4.0 the statement can be executed with Exec in a read-only transaction (sql.TxOptions.ReadOnly): it is
+// committed with the other statements of the transaction as a single ExecuteTransaction, and must read a single item
+// by its key. The item is read with a TxResultCollector.
+//
+// @Since v1.4.0 support WITH AllowScan=true clause to execute the statement even if it would scan the table while
+// Config.DenyScans is enabled.
 type StmtSelect struct {
 	*StmtExecutable
 	withOptsStr string
+	withParams  []string  // keys of the WITH options whose values are placeholders, in order
+	scan        *scanPlan // if not nil, the statement is executed as a parallel Scan
 }
 
+// selectWithParamKeys lists the WITH options of SELECT statements whose values can be placeholders.
+var selectWithParamKeys = map[string]bool{"PAGETOKEN": true, "NEXTTOKEN": true, "PAGESIZE": true}
+
 func (s *StmtSelect) parse() error {
 	if err := s.parseWithOpts(s.withOptsStr); err != nil {
 		return err
@@ -117,41 +307,191 @@ func (s *StmtSelect) parse() error {
 		// Remove LIMIT keyword and value from query
 		s.query = strings.TrimSpace(reLimit.ReplaceAllString(s.query, ""))
 	}
-	return s.StmtExecutable.parse()
+	for _, k := range []string{"NUMBER_MODE", "NUMBERMODE"} {
+		if len(s.withOpts[k]) > 0 {
+			if _, err := ParseNumberMode(s.withOpts[k].FirstString()); err != nil {
+				return err
+			}
+		}
+	}
+	for withOptsStr := s.withOptsStr; ; {
+		matches := reWithOpts.FindStringSubmatch(withOptsStr)
+		if matches == nil {
+			break
+		}
+		withOptsStr = withOptsStr[len(matches[0]):]
+		if k := strings.ToUpper(matches[2]); strings.TrimSuffix(matches[3], ",") == "?" {
+			if !selectWithParamKeys[k] {
+				return fmt.Errorf("placeholder is not supported for WITH %s", k)
+			}
+			s.withParams = append(s.withParams, k)
+		}
+	}
+	if prefetch := s.withOpts["PREFETCH"].FirstString(); prefetch != "" {
+		if n, err := strconv.Atoi(prefetch); err != nil || n < 0 {
+			return fmt.Errorf("invalid Prefetch value: %s", prefetch)
+		}
+	}
+	if pageSize := s.withOpts["PAGESIZE"].FirstString(); pageSize != "" && pageSize != "?" {
+		if _, err := parsePageSize(pageSize); err != nil {
+			return err
+		}
+	}
+	if allowScan := s.withOpts["ALLOWSCAN"].FirstString(); allowScan != "" {
+		if _, err := strconv.ParseBool(allowScan); err != nil {
+			return fmt.Errorf("invalid AllowScan value: %s", allowScan)
+		}
+	}
+	if segments := s.withOpts["SEGMENTS"].FirstString(); segments != "" {
+		n, err := parseSegments(segments)
+		if err != nil {
+			return err
+		}
+		if len(s.withOpts["PAGETOKEN"]) > 0 || len(s.withOpts["NEXTTOKEN"]) > 0 {
+			return fmt.Errorf("%w: PageToken", ErrUnsupportedScan)
+		}
+		if s.scan, err = parseScanPlan(s.query, n); err != nil {
+			return err
+		}
+	}
+	if err := s.StmtExecutable.parse(); err != nil {
+		return err
+	}
+	s.numInput += len(s.withParams)
+	return nil
+}
+
+func parsePageSize(val string) (int32, error) {
+	pageSize, err := strconv.ParseInt(strings.TrimSpace(val), 10, 32)
+	if err != nil || pageSize <= 0 {
+		return 0, fmt.Errorf("invalid PageSize value: %s", val)
+	}
+	return int32(pageSize), nil
+}
+
+// pagingOptions resolves the PageToken and PageSize options of the statement, binding placeholders to the last
+// values. It returns the values left for the statement, the customization of the call to DynamoDB and the number of
+// items to skip from the first page.
+func (s *StmtSelect) pagingOptions(values []driver.NamedValue) ([]driver.NamedValue, []func(*dynamodb.ExecuteStatementInput), int, error) {
+	n := len(values) - len(s.withParams)
+	if n < 0 {
+		return nil, nil, 0, fmt.Errorf("expected %d parameters, received %d", s.numInput, len(values))
+	}
+	opts := map[string]string{}
+	for k := range selectWithParamKeys {
+		opts[k] = s.withOpts[k].FirstString()
+	}
+	for i, k := range s.withParams {
+		switch v := values[n+i].Value.(type) {
+		case nil:
+			opts[k] = ""
+		case []byte:
+			opts[k] = string(v)
+		default:
+			opts[k] = fmt.Sprint(v)
+		}
+	}
+
+	var optFns []func(*dynamodb.ExecuteStatementInput)
+	skip := 0
+	token := opts["PAGETOKEN"]
+	if token == "" {
+		token = opts["NEXTTOKEN"]
+	}
+	if token != "" {
+		pt, err := decodePageToken(token, s.conn.pageTokenSecret)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		skip = pt.Offset
+		if pt.NextToken != "" {
+			optFns = append(optFns, func(input *dynamodb.ExecuteStatementInput) {
+				input.NextToken = aws.String(pt.NextToken)
+			})
+		}
+	}
+	if opts["PAGESIZE"] != "" {
+		pageSize, err := parsePageSize(opts["PAGESIZE"])
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		optFns = append(optFns, func(input *dynamodb.ExecuteStatementInput) {
+			input.Limit = aws.Int32(pageSize)
+		})
+	}
+	return values[:n], optFns, skip, nil
 }
 
 // Exec implements driver.Stmt/Exec.
-// This function is not implemented, use Query instead.
-func (s *StmtSelect) Exec(_ []driver.Value) (driver.Result, error) {
-	return nil, errors.New("this operation is not supported, please use Query")
+// This function is only supported in read-only transactions, use Query instead.
+func (s *StmtSelect) Exec(values []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
-// This function is not implemented, use QueryContext instead.
-func (s *StmtSelect) ExecContext(_ context.Context, _ []driver.NamedValue) (driver.Result, error) {
-	return nil, errors.New("this operation is not supported, please use QueryContext")
+// This function is only supported in read-only transactions, use QueryContext instead.
+//
+// @Since v1.4.0 adds the statement to the ongoing read-only transaction. RowsAffected is the number of items read.
+func (s *StmtSelect) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
+	if s.conn.txMode != txStarted {
+		return nil, errors.New("this operation is not supported, please use QueryContext")
+	}
+	if !s.conn.txReadOnly {
+		return nil, errors.New("SELECT statements are only supported in read-only transactions")
+	}
+	if s.scan != nil || s.limit != nil || len(s.withOpts["PAGETOKEN"]) > 0 || len(s.withOpts["NEXTTOKEN"]) > 0 ||
+		len(s.withOpts["PAGESIZE"]) > 0 {
+		return nil, errors.New("LIMIT, Segments, PageToken and PageSize are not supported in transactions")
+	}
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
+	if errors.Is(err, ErrInTx) {
+		return &TxResultNoResultSet{outputFn: outputFn, readItems: true}, nil
+	}
+	return nil, err
 }
 
 // Query implements driver.Stmt/Query.
 func (s *StmtSelect) Query(values []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
 // @Available since v0.2.0
 func (s *StmtSelect) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
-	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
-	// TODO Query is not supported yet in tx mode
-	// if err == ErrInTx {
-	// 	return &TxResultResultSet{wrap: ResultResultSet{err: err}, outputFn: outputFn}, nil
-	// }
+	if allowScan, _ := strconv.ParseBool(s.withOpts["ALLOWSCAN"].FirstString()); s.conn.denyScans && s.scan == nil && !allowScan {
+		if err := s.conn.checkScan(ctx, s.query); err != nil {
+			return nil, err
+		}
+	}
+	if s.conn.txMode == txStarted {
+		return nil, errQueryInTx
+	}
+	if s.scan != nil {
+		return s.queryScan(ctx, values)
+	}
+	values, optFns, skip, err := s.pagingOptions(values)
+	if err != nil {
+		return nil, err
+	}
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values, optFns...)
 	result := (&ResultResultSet{
 		stmt:       outputFn(),
-		columnList: extractSelectedColumnList(s.query)}).init()
+		columnList: extractSelectedColumnList(s.query),
+		schema:     lookupTableSchema(extractSelectedTable(s.query)),
+		skip:       skip}).init()
 	return result, err
 }
 
+// extractSelectedTable returns the name of the table the query selects from, or an empty string if not found.
+func extractSelectedTable(query string) string {
+	match := reSelectTable.FindStringSubmatch(query)
+	if len(match) == 0 {
+		return ""
+	}
+	return match[1] + match[2]
+}
+
 // extractSelectedColumnList returns a slice of selected column names from the query.
 // If the query contains "*" or no selected column, an empty slice is returned.
 func extractSelectedColumnList(query string) []string {
@@ -182,60 +522,201 @@ func extractSelectedColumnList(query string) []string {
 
 /*----------------------------------------------------------------------*/
 
+// stmtNative is the base implementation for QUERY and SCAN statements, which are executed with the native Query and
+// Scan APIs instead of PartiQL.
+type stmtNative struct {
+	*Stmt
+	withOptsStr string
+	plan        *scanPlan
+}
+
+func (s *stmtNative) parse() error {
+	if err := s.parseWithOpts(s.withOptsStr); err != nil {
+		return err
+	}
+	for k, v := range s.withOpts {
+		if v.FirstString() == "?" {
+			return fmt.Errorf("placeholder is not supported for WITH %s", k)
+		}
+	}
+	var err error
+	if s.plan, s.limit, err = parseNativePlan(s.query); err != nil {
+		return err
+	}
+	for _, k := range []string{"NUMBER_MODE", "NUMBERMODE"} {
+		if len(s.withOpts[k]) > 0 {
+			if _, err = ParseNumberMode(s.withOpts[k].FirstString()); err != nil {
+				return err
+			}
+		}
+	}
+	if pageSize := s.withOpts["PAGESIZE"].FirstString(); pageSize != "" {
+		if _, err = parsePageSize(pageSize); err != nil {
+			return err
+		}
+	}
+	if segments := s.withOpts["SEGMENTS"].FirstString(); segments != "" {
+		if s.plan.keyCondition != "" {
+			return errors.New("WITH Segments is not supported by QUERY statements")
+		}
+		if s.plan.segments, err = parseSegments(segments); err != nil {
+			return err
+		}
+	}
+	s.numInput = s.plan.numParams
+	return nil
+}
+
+func (s *stmtNative) validate() error {
+	return nil
+}
+
+// Exec implements driver.Stmt/Exec.
+// This function is not implemented, use Query instead.
+func (s *stmtNative) Exec(_ []driver.Value) (driver.Result, error) {
+	return nil, errors.New("this operation is not supported, please use Query")
+}
+
+// ExecContext implements driver.StmtExecContext/ExecContext.
+// This function is not implemented, use QueryContext instead.
+func (s *stmtNative) ExecContext(_ context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	return nil, errors.New("this operation is not supported, please use QueryContext")
+}
+
+// Query implements driver.Stmt/Query.
+func (s *stmtNative) Query(values []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
+}
+
+// QueryContext implements driver.StmtQueryContext/QueryContext.
+func (s *stmtNative) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
+	var pageSize *int32
+	if val := s.withOpts["PAGESIZE"].FirstString(); val != "" {
+		n, _ := parsePageSize(val)
+		pageSize = aws.Int32(n)
+	}
+	return s.conn.executeNative(ctx, s.Stmt, s.plan, values, pageSize, s.plan.columns)
+}
+
+// StmtQuery implements "QUERY" statement, which is executed with the native Query API, guaranteeing key-condition
+// access.
+//
+// Syntax:
+//
+//	QUERY <table>[.<index>] WHERE <partition-key> = <value> [AND <sort-key-condition>]
+//		[FILTER <condition>] [PROJECT <attr>[, <attr>...]] [ASC|DESC] [LIMIT <n>]
+//		[WITH ConsistentRead=true] [WITH PageSize=<n>] [WITH number_mode=<mode>]
+//
+// The sort key condition is one of "<sort-key> (=|<|<=|>|>=) <value>", "<sort-key> BETWEEN <value> AND <value>",
+// "<sort-key> BEGINS_WITH <value>" or "begins_with(<sort-key>, <value>)". The FILTER condition supports the same
+// subset as the WHERE clause of SELECT statements executed WITH Segments=<n>, see StmtSelect. Values are
+// placeholders (?), strings, numbers, true, false or NULL. DESC returns items in descending order of the sort key.
+//
+// Example:
+//
+//	QUERY "orders" WHERE customer = ? AND created BETWEEN ? AND ? FILTER status <> 'cancelled' PROJECT id, total DESC LIMIT 10
+//
+// @Available since v1.4.0
+type StmtQuery struct {
+	*stmtNative
+}
+
+// StmtScan implements "SCAN" statement, which is executed with the native Scan API.
+//
+// Syntax:
+//
+//	SCAN <table>[.<index>] [FILTER <condition>] [PROJECT <attr>[, <attr>...]] [LIMIT <n>]
+//		[WITH Segments=<n>] [WITH ConsistentRead=true] [WITH PageSize=<n>] [WITH number_mode=<mode>]
+//
+// With Segments=<n>, the table is scanned in n segments concurrently, see StmtSelect.
+//
+// @Available since v1.4.0
+type StmtScan struct {
+	*stmtNative
+}
+
+/*----------------------------------------------------------------------*/
+
 // StmtUpdate implements "UPDATE" statement.
 //
 // Syntax: follow "PartiQL update statements for DynamoDB" https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ql-reference.update.html
 //
-// Note: StmtUpdate returns the updated item by appending "RETURNING ALL OLD *" to the statement.
+// Note: StmtUpdate returns the updated item by appending "RETURNING ALL OLD *" to the statement (except in transactions).
+//
+// @Since v1.4.0 "RETURNING ALL OLD *" is appended when the statement is executed, not when it is prepared, so that a
+// prepared statement can be used both inside and outside transactions.
+//
+// @Since v1.4.0 RETURNING clause is supported in transactions: execute the statement with Exec, the returned items are
+// read with a TxResultCollector.
+//
+// @Since v1.4.0 the statement is refused with ErrScanDenied if Config.DenyScans is enabled and its WHERE clause has
+// no equality condition on the partition key.
+//
+// @Since v1.4.0 the statement can end with "WITH OnConditionFail=error" to return a *ConditionFailedError, carrying
+// the current item if any, when its condition check fails (e.g. the item does not exist or the WHERE clause does not
+// match). By default, a failed condition check results in no affected row.
 type StmtUpdate struct {
 	*StmtExecutable
 }
 
 func (s *StmtUpdate) parse() error {
-	if !reReturning.MatchString(s.query) && s.conn.txMode == txNone {
-		s.query += " RETURNING ALL OLD *"
+	if err := s.parseOnConditionFail(OnConditionFailIgnore); err != nil {
+		return err
 	}
+	s.autoReturning = !reReturning.MatchString(s.query)
 	return s.StmtExecutable.parse()
 }
 
 // Query implements driver.Stmt/Query.
 func (s *StmtUpdate) Query(values []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
 // @Available since v0.2.0
 func (s *StmtUpdate) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
-	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
-	result := (&ResultResultSet{stmt: outputFn()}).init()
-	if err == nil || IsAwsError(err, "ConditionalCheckFailedException") {
-		err = nil
+	if s.conn.txMode == txStarted {
+		return nil, errQueryInTx
 	}
+	if s.conn.denyScans {
+		if err := s.conn.checkScan(ctx, s.query); err != nil {
+			return nil, err
+		}
+	}
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values, s.conditionOptFns()...)
+	if outputFn == nil {
+		return nil, err
+	}
+	result := (&ResultResultSet{stmt: outputFn()}).init()
+	err = s.checkCondition(err)
 	return result, err
 }
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtUpdate) Exec(values []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
 func (s *StmtUpdate) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
-	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
+	if s.conn.denyScans {
+		if err := s.conn.checkScan(ctx, s.query); err != nil {
+			return nil, err
+		}
+	}
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values, s.conditionOptFns()...)
 	if errors.Is(err, ErrInTx) {
-		return &TxResultNoResultSet{outputFn: outputFn}, nil
+		return &TxResultNoResultSet{outputFn: outputFn, countItems: reReturning.MatchString(s.query)}, nil
 	}
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = int64(len(outputFn().output.Items))
 	}
-	if IsAwsError(err, "ConditionalCheckFailedException") {
-		err = nil
-	}
-	return &ResultNoResultSet{err: err, affectedRows: affectedRows}, err
+	err = s.checkCondition(err)
+	return &ResultNoResultSet{err: err, affectedRows: affectedRows, consumedCapacity: outputFn.consumedCapacity()}, err
 }
 
 /*----------------------------------------------------------------------*/
@@ -244,54 +725,80 @@ func (s *StmtUpdate) ExecContext(ctx context.Context, values []driver.NamedValue
 //
 // Syntax: follow "PartiQL delete statements for DynamoDB" https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ql-reference.delete.html
 //
-// Note: StmtDelete returns the deleted item by appending "RETURNING ALL OLD *" to the statement.
+// Note: StmtDelete returns the deleted item by appending "RETURNING ALL OLD *" to the statement (except in transactions).
+//
+// @Since v1.4.0 "RETURNING ALL OLD *" is appended when the statement is executed, not when it is prepared, so that a
+// prepared statement can be used both inside and outside transactions.
+//
+// @Since v1.4.0 RETURNING clause is supported in transactions: execute the statement with Exec, the returned items are
+// read with a TxResultCollector.
+//
+// @Since v1.4.0 the statement is refused with ErrScanDenied if Config.DenyScans is enabled and its WHERE clause has
+// no equality condition on the partition key.
+//
+// @Since v1.4.0 the statement can end with "WITH OnConditionFail=error" to return a *ConditionFailedError, carrying
+// the current item if any, when its condition check fails (e.g. the item does not exist or the WHERE clause does not
+// match). By default, a failed condition check results in no affected row.
 type StmtDelete struct {
 	*StmtExecutable
 }
 
 func (s *StmtDelete) parse() error {
-	if !reReturning.MatchString(s.query) && s.conn.txMode == txNone {
-		s.query += " RETURNING ALL OLD *"
+	if err := s.parseOnConditionFail(OnConditionFailIgnore); err != nil {
+		return err
 	}
+	s.autoReturning = !reReturning.MatchString(s.query)
 	return s.StmtExecutable.parse()
 }
 
 // Query implements driver.Stmt/Query.
 func (s *StmtDelete) Query(values []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.QueryContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
 // @Available since v0.2.0
 func (s *StmtDelete) QueryContext(ctx context.Context, values []driver.NamedValue) (driver.Rows, error) {
-	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
-	result := (&ResultResultSet{stmt: outputFn()}).init()
-	if err == nil || IsAwsError(err, "ConditionalCheckFailedException") {
-		err = nil
+	if s.conn.txMode == txStarted {
+		return nil, errQueryInTx
+	}
+	if s.conn.denyScans {
+		if err := s.conn.checkScan(ctx, s.query); err != nil {
+			return nil, err
+		}
 	}
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values, s.conditionOptFns()...)
+	if outputFn == nil {
+		return nil, err
+	}
+	result := (&ResultResultSet{stmt: outputFn()}).init()
+	err = s.checkCondition(err)
 	return result, err
 }
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtDelete) Exec(values []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), ValuesToNamedValues(values))
+	return s.ExecContext(context.Background(), ValuesToNamedValues(values))
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
 //
 // @Available since v0.2.0
 func (s *StmtDelete) ExecContext(ctx context.Context, values []driver.NamedValue) (driver.Result, error) {
-	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values)
+	if s.conn.denyScans {
+		if err := s.conn.checkScan(ctx, s.query); err != nil {
+			return nil, err
+		}
+	}
+	outputFn, err := s.conn.executeContext(ctx, s.Stmt, values, s.conditionOptFns()...)
 	if errors.Is(err, ErrInTx) {
-		return &TxResultNoResultSet{outputFn: outputFn}, nil
+		return &TxResultNoResultSet{outputFn: outputFn, countItems: reReturning.MatchString(s.query)}, nil
 	}
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = int64(len(outputFn().output.Items))
 	}
-	if IsAwsError(err, "ConditionalCheckFailedException") {
-		err = nil
-	}
-	return &ResultNoResultSet{err: err, affectedRows: affectedRows}, err
+	err = s.checkCondition(err)
+	return &ResultNoResultSet{err: err, affectedRows: affectedRows, consumedCapacity: outputFn.consumedCapacity()}, err
 }
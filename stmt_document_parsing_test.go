@@ -31,6 +31,12 @@ func Test_Stmt_Select_parse(t *testing.T) {
 		{name: "invalid limit", sql: `SELECT * FROM "table" LIMIT a`, mustError: true},
 		{name: "invalid limit value", sql: `SELECT * FROM "table" LIMIT -2`, mustError: true},
 		{name: "limit value with opt", sql: `SELECT * FROM "table" LIMIT 1 WITH CONSTENCY=strong`, mustError: false, limit: aws.Int32(1), afterSql: `SELECT * FROM "table"`},
+		{name: "number mode", sql: `SELECT * FROM "table" WITH number_mode=json`, afterSql: `SELECT * FROM "table"`},
+		{name: "invalid number mode", sql: `SELECT * FROM "table" WITH number_mode=decimal`, mustError: true},
+		{name: "segments", sql: `SELECT * FROM "table" WHERE a>? LIMIT 5 WITH Segments=4`, numInput: 1, limit: aws.Int32(5), afterSql: `SELECT * FROM "table" WHERE a>?`},
+		{name: "invalid segments", sql: `SELECT * FROM "table" WITH Segments=0`, mustError: true},
+		{name: "segments with page token", sql: `SELECT * FROM "table" WITH Segments=2 WITH PageToken=?`, mustError: true},
+		{name: "segments with unsupported statement", sql: `SELECT * FROM "table" WHERE trim(a)='x' WITH Segments=2`, mustError: true},
 	}
 
 	for _, testCase := range testData {
@@ -124,6 +130,14 @@ func Test_Stmt_Select_parse_withopts(t *testing.T) {
 		name:     "with read consistency and projection",
 		sql:      `SELECT * FROM "table" WITH CONSISTENTREAD=strong WITH PROJECTION=ALL`,
 		expected: map[string]OptStrings{"CONSISTENTREAD": {"strong"}, "PROJECTION": {"ALL"}},
+	}, {
+		name:     "with number mode",
+		sql:      `SELECT * FROM "table" WITH number_mode=int64`,
+		expected: map[string]OptStrings{"NUMBER_MODE": {"int64"}},
+	}, {
+		name:     "with page token and page size",
+		sql:      `SELECT * FROM "table" WHERE id=? WITH PageToken=? WITH PageSize=10`,
+		expected: map[string]OptStrings{"PAGETOKEN": {"?"}, "PAGESIZE": {"10"}},
 	},
 	}
 
@@ -144,3 +158,47 @@ func Test_Stmt_Select_parse_withopts(t *testing.T) {
 		})
 	}
 }
+
+func Test_Stmt_Upsert_parse(t *testing.T) {
+	testName := "Test_Stmt_Upsert_parse"
+	testData := []struct {
+		name      string
+		sql       string
+		tableName string
+		numInput  int
+		returnOld bool
+		mustError bool
+	}{
+		{name: "upsert", sql: `UPSERT INTO "table" VALUE {'id': ?, 'name': 'a''b'}`, tableName: "table", numInput: 1},
+		{name: "replace", sql: `replace into tbl value {'id': 1, 'tags': <<?, ?>>, 'doc': {'l': [?, true, null]}}`, tableName: "tbl", numInput: 3},
+		{name: "returning", sql: "UPSERT INTO \"table\"\nVALUE {'id': ?} RETURNING ALL OLD *", tableName: "table", numInput: 1, returnOld: true},
+		{name: "empty document", sql: `UPSERT INTO "table" VALUE {}`, tableName: "table"},
+
+		{name: "no document", sql: `UPSERT INTO "table" VALUE ?`, mustError: true},
+		{name: "returning new", sql: `UPSERT INTO "table" VALUE {'id': ?} RETURNING ALL NEW *`, mustError: true},
+		{name: "unquoted key", sql: `UPSERT INTO "table" VALUE {id: ?}`, mustError: true},
+		{name: "unterminated document", sql: `UPSERT INTO "table" VALUE {'id': ?`, mustError: true},
+		{name: "trailing tokens", sql: `UPSERT INTO "table" VALUE {'id': ?} WHERE id = 1`, mustError: true},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := parseQuery(nil, testCase.sql)
+			if testCase.mustError {
+				if err == nil {
+					t.Fatalf("%s failed: parsing must fail", testName+"/"+testCase.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			stmt, ok := s.(*StmtUpsert)
+			if !ok {
+				t.Fatalf("%s failed: expected StmtUpsert but received %T", testName+"/"+testCase.name, s)
+			}
+			if stmt.tableName != testCase.tableName || stmt.numInput != testCase.numInput || stmt.returnOld != testCase.returnOld {
+				t.Fatalf("%s failed: unexpected table %s, %d input parameters, returnOld %v", testName+"/"+testCase.name, stmt.tableName, stmt.numInput, stmt.returnOld)
+			}
+		})
+	}
+}
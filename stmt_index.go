@@ -125,7 +125,7 @@ func (s *StmtDescribeLSI) ExecContext(_ context.Context, _ []driver.NamedValue)
 
 // Query implements driver.Stmt/Query.
 func (s *StmtDescribeLSI) Query(_ []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), nil)
+	return s.QueryContext(context.Background(), nil)
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
@@ -135,7 +135,9 @@ func (s *StmtDescribeLSI) QueryContext(ctx context.Context, _ []driver.NamedValu
 	input := &dynamodb.DescribeTableInput{
 		TableName: &s.tableName,
 	}
-	output, err := s.conn.client.DescribeTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	output, err := s.conn.client.DescribeTable(ctx, input)
 	result := &RowsDescribeIndex{count: 0}
 	if err == nil {
 		for _, lsi := range output.Table.LocalSecondaryIndexes {
@@ -275,7 +277,7 @@ func (s *StmtCreateGSI) QueryContext(_ context.Context, _ []driver.NamedValue) (
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtCreateGSI) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
@@ -319,7 +321,9 @@ func (s *StmtCreateGSI) ExecContext(ctx context.Context, _ []driver.NamedValue)
 		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Create: gsiInput}},
 	}
 
-	_, err := s.conn.client.UpdateTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	_, err := s.conn.client.UpdateTable(ctx, input)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
@@ -368,7 +372,7 @@ func (s *StmtDescribeGSI) ExecContext(_ context.Context, _ []driver.NamedValue)
 
 // Query implements driver.Stmt/Query.
 func (s *StmtDescribeGSI) Query(_ []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), nil)
+	return s.QueryContext(context.Background(), nil)
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
@@ -378,7 +382,9 @@ func (s *StmtDescribeGSI) QueryContext(ctx context.Context, _ []driver.NamedValu
 	input := &dynamodb.DescribeTableInput{
 		TableName: &s.tableName,
 	}
-	output, err := s.conn.client.DescribeTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	output, err := s.conn.client.DescribeTable(ctx, input)
 	result := &RowsDescribeIndex{count: 0}
 	if err == nil {
 		for _, gsi := range output.Table.GlobalSecondaryIndexes {
@@ -473,7 +479,7 @@ func (s *StmtAlterGSI) QueryContext(_ context.Context, _ []driver.NamedValue) (d
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtAlterGSI) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
@@ -492,7 +498,9 @@ func (s *StmtAlterGSI) ExecContext(ctx context.Context, _ []driver.NamedValue) (
 		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Update: gsiInput}},
 	}
 
-	_, err := s.conn.client.UpdateTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	_, err := s.conn.client.UpdateTable(ctx, input)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
@@ -540,7 +548,7 @@ func (s *StmtDropGSI) QueryContext(_ context.Context, _ []driver.NamedValue) (dr
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtDropGSI) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
@@ -552,7 +560,9 @@ func (s *StmtDropGSI) ExecContext(ctx context.Context, _ []driver.NamedValue) (d
 		TableName:                   &s.tableName,
 		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Delete: gsiInput}},
 	}
-	_, err := s.conn.client.UpdateTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	_, err := s.conn.client.UpdateTable(ctx, input)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
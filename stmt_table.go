@@ -171,7 +171,7 @@ func (s *StmtCreateTable) QueryContext(_ context.Context, _ []driver.NamedValue)
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtCreateTable) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/Exec.
@@ -229,7 +229,9 @@ func (s *StmtCreateTable) ExecContext(ctx context.Context, _ []driver.NamedValue
 			WriteCapacityUnits: s.wcu,
 		}
 	}
-	_, err := s.conn.client.CreateTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	_, err := s.conn.client.CreateTable(ctx, input)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
@@ -269,14 +271,16 @@ func (s *StmtListTables) ExecContext(_ context.Context, _ []driver.NamedValue) (
 
 // Query implements driver.Stmt/Query.
 func (s *StmtListTables) Query(_ []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), nil)
+	return s.QueryContext(context.Background(), nil)
 }
 
 // QueryContext implements driver.StmtQueryContext/QueryContext.
 //
 // @Available since v0.2.0
 func (s *StmtListTables) QueryContext(ctx context.Context, _ []driver.NamedValue) (driver.Rows, error) {
-	output, err := s.conn.client.ListTables(s.conn.ensureContext(ctx), &dynamodb.ListTablesInput{})
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	output, err := s.conn.client.ListTables(ctx, &dynamodb.ListTablesInput{})
 	var rows driver.Rows
 	if err == nil {
 		rows = &RowsListTables{
@@ -405,7 +409,7 @@ func (s *StmtAlterTable) QueryContext(_ []driver.NamedValue) (driver.Rows, error
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtAlterTable) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/ExecContext.
@@ -429,7 +433,9 @@ func (s *StmtAlterTable) ExecContext(ctx context.Context, _ []driver.NamedValue)
 			}
 		}
 	}
-	_, err := s.conn.client.UpdateTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	_, err := s.conn.client.UpdateTable(ctx, input)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
@@ -473,7 +479,7 @@ func (s *StmtDropTable) QueryContext(_ context.Context, _ []driver.NamedValue) (
 
 // Exec implements driver.Stmt/Exec.
 func (s *StmtDropTable) Exec(_ []driver.Value) (driver.Result, error) {
-	return s.ExecContext(s.conn.newContext(), nil)
+	return s.ExecContext(context.Background(), nil)
 }
 
 // ExecContext implements driver.StmtExecContext/Exec.
@@ -483,7 +489,9 @@ func (s *StmtDropTable) ExecContext(ctx context.Context, _ []driver.NamedValue)
 	input := &dynamodb.DeleteTableInput{
 		TableName: &s.tableName,
 	}
-	_, err := s.conn.client.DeleteTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	_, err := s.conn.client.DeleteTable(ctx, input)
 	affectedRows := int64(0)
 	if err == nil {
 		affectedRows = 1
@@ -527,7 +535,7 @@ func (s *StmtDescribeTable) ExecContext(_ context.Context, _ []driver.NamedValue
 
 // Query implements driver.Stmt/Query.
 func (s *StmtDescribeTable) Query(_ []driver.Value) (driver.Rows, error) {
-	return s.QueryContext(s.conn.newContext(), nil)
+	return s.QueryContext(context.Background(), nil)
 }
 
 // QueryContext implements driver.StmtQueryContext/Query.
@@ -537,7 +545,9 @@ func (s *StmtDescribeTable) QueryContext(ctx context.Context, _ []driver.NamedVa
 	input := &dynamodb.DescribeTableInput{
 		TableName: &s.tableName,
 	}
-	output, err := s.conn.client.DescribeTable(s.conn.ensureContext(ctx), input)
+	ctx, cancel := s.conn.requestContext(ctx)
+	defer cancel()
+	output, err := s.conn.client.DescribeTable(ctx, input)
 	result := &RowsDescribeTable{count: 0}
 	if err == nil {
 		result.count = 1
@@ -0,0 +1,119 @@
+package godynamo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+var (
+	ErrUnknownHTTPClientID = errors.New("unknown HTTP client ID")
+	ErrClientKeyRequired   = errors.New("client certificate and client key must be specified together")
+)
+
+// httpClients holds the HTTP clients registered via RegisterHTTPClient.
+var (
+	httpClientsLock = &sync.RWMutex{}
+	httpClients     = map[string]aws.HTTPClient{}
+)
+
+// RegisterHTTPClient registers an HTTP client (e.g. *http.Client) to be used to call AWS services. The client can be
+// referenced in the connection string via HttpClientId=<id>, or via Config.HTTPClientID.
+//
+// The timeout and transport settings of the connection string do not apply to registered clients.
+//
+// @Available since v1.4.0
+func RegisterHTTPClient(id string, client aws.HTTPClient) {
+	httpClientsLock.Lock()
+	defer httpClientsLock.Unlock()
+	httpClients[id] = client
+}
+
+// DeregisterHTTPClient removes the registered HTTP client.
+//
+// @Available since v1.4.0
+func DeregisterHTTPClient(id string) {
+	httpClientsLock.Lock()
+	defer httpClientsLock.Unlock()
+	delete(httpClients, id)
+}
+
+// httpClient returns the HTTP client used to call AWS services: Config.HTTPClient, the client registered as
+// Config.HTTPClientID, or a new client built from the timeout and transport settings in the Config.
+func (cfg Config) httpClient() (aws.HTTPClient, error) {
+	if cfg.HTTPClient != nil {
+		return cfg.HTTPClient, nil
+	}
+	if cfg.HTTPClientID != "" {
+		httpClientsLock.RLock()
+		defer httpClientsLock.RUnlock()
+		client, ok := httpClients[cfg.HTTPClientID]
+		if !ok {
+			return nil, ErrUnknownHTTPClientID
+		}
+		return client, nil
+	}
+
+	var proxyURL *url.URL
+	if cfg.ProxyURL != "" {
+		var err error
+		if proxyURL, err = url.Parse(cfg.ProxyURL); err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %s", err)
+		}
+	}
+	var rootCAs *x509.CertPool
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %s", err)
+		}
+		if rootCAs, err = x509.SystemCertPool(); err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA bundle %s", cfg.CABundle)
+		}
+	}
+	var certs []tls.Certificate
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return nil, ErrClientKeyRequired
+	}
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %s", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return awshttp.NewBuildableClient().WithTimeout(cfg.timeout()).WithTransportOptions(func(t *http.Transport) {
+		if proxyURL != nil {
+			t.Proxy = http.ProxyURL(proxyURL)
+		}
+		if rootCAs != nil || len(certs) > 0 {
+			if t.TLSClientConfig == nil {
+				t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			if rootCAs != nil {
+				t.TLSClientConfig.RootCAs = rootCAs
+			}
+			if len(certs) > 0 {
+				t.TLSClientConfig.Certificates = certs
+			}
+		}
+		if cfg.MaxIdleConns > 0 {
+			t.MaxIdleConns = cfg.MaxIdleConns
+		}
+		if cfg.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		}
+	}), nil
+}
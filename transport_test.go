@@ -0,0 +1,168 @@
+package godynamo
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+func _okStubHandler(_ string, _ map[string]interface{}) stubResponse {
+	return stubResponse{body: map[string]interface{}{"TableNames": []string{}}}
+}
+
+func _writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatalf("error writing %s: %s", path, err)
+	}
+	return path
+}
+
+// _generateClientCert generates a self-signed client certificate, returning the paths to the certificate and key
+// files, and the certificate itself.
+func _generateClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "godynamo-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return _writePEM(t, dir, "client.pem", "CERTIFICATE", der), _writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDer), cert
+}
+
+func _ping(connStr string) error {
+	db, err := sql.Open("godynamo", connStr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	return db.PingContext(context.Background())
+}
+
+func TestConfig_httpClient_caBundle(t *testing.T) {
+	testName := "TestConfig_httpClient_caBundle"
+	server := newUnstartedStubDynamoDBServer(_okStubHandler)
+	server.StartTLS()
+	defer server.Close()
+	caBundle := _writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	if err := _ping(server.connStr("MaxAttempts=1")); err == nil {
+		t.Fatalf("%s failed: expected certificate error without CA bundle", testName)
+	}
+	if err := _ping(server.connStr("MaxAttempts=1;CaBundle=" + caBundle)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err := _ping(server.connStr("CaBundle=" + filepath.Join(t.TempDir(), "not-found.pem"))); err == nil {
+		t.Fatalf("%s failed: expected error for missing CA bundle", testName)
+	}
+}
+
+func TestConfig_httpClient_clientCert(t *testing.T) {
+	testName := "TestConfig_httpClient_clientCert"
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := _generateClientCert(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := newUnstartedStubDynamoDBServer(_okStubHandler)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caBundle := _writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	if err := _ping(server.connStr("MaxAttempts=1;CaBundle=" + caBundle)); err == nil {
+		t.Fatalf("%s failed: expected error without client certificate", testName)
+	}
+	if err := _ping(server.connStr("MaxAttempts=1;CaBundle=" + caBundle + ";ClientCert=" + certFile + ";ClientKey=" + keyFile)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err := (&Driver{}).OpenConnector(server.connStr("ClientCert=" + certFile)); err != ErrClientKeyRequired {
+		t.Fatalf("%s failed: expected ErrClientKeyRequired but received %v", testName, err)
+	}
+}
+
+func TestConfig_httpClient_proxy(t *testing.T) {
+	testName := "TestConfig_httpClient_proxy"
+	proxy := newStubDynamoDBServer(_okStubHandler)
+	defer proxy.Close()
+
+	// the endpoint is not resolvable, requests must go through the proxy
+	connStr := "Region=us-east-1;AkId=id;Secret_Key=secret;Endpoint=http://dynamodb.godynamo.invalid;MaxAttempts=1;ProxyUrl=" + proxy.URL
+	if err := _ping(connStr); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n := proxy.numCalls("ListTables"); n != 1 {
+		t.Fatalf("%s failed: expected %d call via proxy but received %d", testName, 1, n)
+	}
+}
+
+func TestConfig_httpClient_idleConns(t *testing.T) {
+	testName := "TestConfig_httpClient_idleConns"
+	client, err := Config{MaxIdleConns: 123, MaxIdleConnsPerHost: 45}.httpClient()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	transport := client.(*awshttp.BuildableClient).GetTransport()
+	if transport.MaxIdleConns != 123 || transport.MaxIdleConnsPerHost != 45 {
+		t.Fatalf("%s failed: expected idle connection limits 123/45 but received %d/%d", testName, transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+}
+
+type countingTransport struct {
+	count int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.count, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRegisterHTTPClient(t *testing.T) {
+	testName := "TestRegisterHTTPClient"
+	server := newStubDynamoDBServer(_okStubHandler)
+	defer server.Close()
+
+	transport := &countingTransport{}
+	RegisterHTTPClient("test-http-client", &http.Client{Transport: transport})
+	defer DeregisterHTTPClient("test-http-client")
+	if err := _ping(server.connStr("HttpClientId=test-http-client")); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if atomic.LoadInt32(&transport.count) != 1 {
+		t.Fatalf("%s failed: expected the registered client to be used", testName)
+	}
+
+	if _, err := (&Driver{}).OpenConnector(server.connStr("HttpClientId=not-registered")); !errors.Is(err, ErrUnknownHTTPClientID) {
+		t.Fatalf("%s failed: expected ErrUnknownHTTPClientID but received %v", testName, err)
+	}
+}
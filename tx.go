@@ -1,16 +1,24 @@
 package godynamo
 
 import (
+	"context"
+	"errors"
 	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 // TxResultNoResultSet is transaction-aware version of ResultNoResultSet.
 //
 // @Available since v0.2.0
 type TxResultNoResultSet struct {
-	hasOutput    bool
-	outputFn     statementOutputWrapper
-	affectedRows int64
+	hasOutput        bool
+	outputFn         statementOutputWrapper
+	countItems       bool // if true, the affected rows are the items returned by the statement (RETURNING clause)
+	readItems        bool // if true, the affected rows are the items read by the statement (SELECT statement)
+	affectedRows     int64
+	affectedRowsErr  error
+	consumedCapacity *types.ConsumedCapacity
 }
 
 // LastInsertId implements driver.Result/LastInsertId.
@@ -19,73 +27,51 @@ func (t *TxResultNoResultSet) LastInsertId() (int64, error) {
 }
 
 // RowsAffected implements driver.Result/RowsAffected.
+//
+// ErrInTx is returned if the transaction has not been committed yet. If committing the transaction failed, the error
+// is returned, e.g. the *TxStatementError of the statement if the transaction was canceled.
+//
+// @Since v1.4.0 the error committing the transaction is returned if failed
+//
+// @Since v1.4.0 the affected rows of a statement with RETURNING clause are the items it returned;
+// ErrRowsAffectedUnavailable is returned if DynamoDB returned none.
 func (t *TxResultNoResultSet) RowsAffected() (int64, error) {
 	if !t.hasOutput {
 		output := t.outputFn()
-		if output != nil {
+		if output != nil && output.txErr != nil {
+			return 0, output.txErr
+		}
+		if output != nil && output.output != nil {
 			t.hasOutput = true
 			t.affectedRows = 1
+			if t.readItems {
+				t.affectedRows = int64(len(output.output.Items))
+			} else if t.countItems {
+				t.affectedRows = int64(len(output.output.Items))
+				if t.affectedRows == 0 {
+					t.affectedRowsErr = ErrRowsAffectedUnavailable
+				}
+			}
+			t.consumedCapacity = output.consumedCapacity()
 		}
 	}
 	if !t.hasOutput {
 		return 0, ErrInTx
 	}
-	return t.affectedRows, nil
+	return t.affectedRows, t.affectedRowsErr
 }
 
-// // TxResultResultSet is transaction-aware version of ResultResultSet.
-// //
-// // @Available since v0.2.0
-// type TxResultResultSet struct {
-// 	wrap      ResultResultSet
-// 	hasOutput bool
-// 	outputFn  executeStatementOutputWrapper
-// }
-//
-// func (r *TxResultResultSet) checkOutput() {
-// 	if !r.hasOutput {
-// 		r.wrap.stmtOutput = r.outputFn()
-// 		fmt.Println("DEBUG", r.wrap.stmtOutput)
-// 		if r.wrap.stmtOutput != nil {
-// 			r.wrap.err = nil
-// 			r.hasOutput = true
-// 			r.wrap.init()
-// 		}
-// 	}
-// }
+// ConsumedCapacity implements CapacityReporter/ConsumedCapacity.
 //
-// // Columns implements driver.Rows/Columns.
-// func (r *TxResultResultSet) Columns() []string {
-// 	r.checkOutput()
-// 	return r.wrap.Columns()
-// }
+// ErrInTx is returned if the transaction has not been committed yet.
 //
-// // ColumnTypeScanType implements driver.RowsColumnTypeScanType/ColumnTypeScanType
-// func (r *TxResultResultSet) ColumnTypeScanType(index int) reflect.Type {
-// 	r.checkOutput()
-// 	return r.wrap.ColumnTypeScanType(index)
-// }
-//
-// // ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName/ColumnTypeDatabaseTypeName
-// func (r *TxResultResultSet) ColumnTypeDatabaseTypeName(index int) string {
-// 	r.checkOutput()
-// 	return r.wrap.ColumnTypeDatabaseTypeName(index)
-// }
-//
-// // Close implements driver.Rows/Close.
-// func (r *TxResultResultSet) Close() error {
-// 	r.checkOutput()
-// 	if !r.hasOutput {
-// 		return ErrInTx
-// 	}
-// 	return nil
-// }
-//
-// // Next implements driver.Rows/Next.
-// func (r *TxResultResultSet) Next(dest []driver.Value) error {
-// 	r.checkOutput()
-// 	return r.wrap.Next(dest)
-// }
+// @Available since v1.4.0
+func (t *TxResultNoResultSet) ConsumedCapacity() (*types.ConsumedCapacity, error) {
+	if _, err := t.RowsAffected(); err != nil && !errors.Is(err, ErrRowsAffectedUnavailable) {
+		return nil, err
+	}
+	return t.consumedCapacity, nil
+}
 
 /*----------------------------------------------------------------------*/
 
@@ -93,12 +79,16 @@ func (t *TxResultNoResultSet) RowsAffected() (int64, error) {
 //
 // @Available since v0.2.0
 type Tx struct {
-	conn *Conn
+	conn  *Conn
+	ctx   context.Context // context the transaction was started with
+	token string          // idempotency token sent when committing
+	// true if token was supplied via WithTxToken
+	tokenSupplied bool
 }
 
 // Commit implements driver.Tx/Commit
 func (t *Tx) Commit() error {
-	return t.conn.commit()
+	return t.conn.commit(t.ctx)
 }
 
 // Rollback implements driver.Tx/Rollback
@@ -0,0 +1,584 @@
+package godynamo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTx_ReadOnly(t *testing.T) {
+	testName := "TestTx_ReadOnly"
+	var statements []interface{}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		if op != "ExecuteTransaction" {
+			return stubError(400, "ValidationException", "unexpected operation "+op)
+		}
+		statements = req["TransactStatements"].([]interface{})
+		responses := make([]interface{}, len(statements))
+		for i, statement := range statements {
+			id := statement.(map[string]interface{})["Parameters"].([]interface{})[0].(map[string]interface{})["S"].(string)
+			if id == "missing" {
+				responses[i] = map[string]interface{}{}
+				continue
+			}
+			responses[i] = map[string]interface{}{"Item": map[string]interface{}{
+				"id":      map[string]interface{}{"S": id},
+				"balance": map[string]interface{}{"N": "100"},
+			}}
+		}
+		return stubResponse{body: map[string]interface{}{"Responses": responses}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	ctx, results := WithTxResultCollector(context.Background())
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	result1, err := tx.ExecContext(ctx, `SELECT id, balance FROM "accounts" WHERE id=?`, "a")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	result2, err := tx.ExecContext(ctx, `SELECT * FROM "ledger" WHERE id=?`, "missing")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO "accounts" VALUE {'id': ?}`, "b"); !errors.Is(err, ErrTxReadOnly) {
+		t.Fatalf("%s failed: expected ErrTxReadOnly but received %v", testName, err)
+	}
+	if _, err = tx.ExecContext(ctx, `SELECT * FROM "accounts" WHERE id=? LIMIT 1`, "a"); err == nil {
+		t.Fatalf("%s failed: expected error for LIMIT in transaction", testName)
+	}
+	if _, err = tx.QueryContext(ctx, `SELECT * FROM "accounts" WHERE id=?`, "a"); !errors.Is(err, ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx but received %v", testName, err)
+	}
+	if _, err = result1.RowsAffected(); !errors.Is(err, ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx but received %v", testName, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(statements) != 2 {
+		t.Fatalf("%s failed: expected 2 statements in transaction but received %d", testName, len(statements))
+	}
+	if statement := statements[0].(map[string]interface{})["Statement"]; statement != `SELECT id, balance FROM "accounts" WHERE id=?` {
+		t.Fatalf("%s failed: unexpected statement %s", testName, statement)
+	}
+	expected := []map[string]interface{}{{"id": "a", "balance": 100.0}}
+	if items, err := results.Items(0); err != nil || !reflect.DeepEqual(items, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, expected, items, err)
+	}
+	if items, err := results.Items(1); err != nil || len(items) != 0 {
+		t.Fatalf("%s failed: expected no item for a missing item but received %#v (error %v)", testName, items, err)
+	}
+	if n, err := result1.RowsAffected(); err != nil || n != 1 {
+		t.Fatalf("%s failed: expected 1 item read but received %d (error %v)", testName, n, err)
+	}
+	if n, err := result2.RowsAffected(); err != nil || n != 0 {
+		t.Fatalf("%s failed: expected no item read but received %d (error %v)", testName, n, err)
+	}
+
+	// a read-write transaction does not accept SELECT statements
+	tx, _ = db.BeginTx(ctx, nil)
+	if _, err = tx.ExecContext(ctx, `SELECT * FROM "accounts" WHERE id=?`, "a"); err == nil {
+		t.Fatalf("%s failed: expected error for SELECT in read-write transaction", testName)
+	}
+	if _, err = tx.QueryContext(ctx, `SELECT * FROM "accounts" WHERE id=?`, "a"); !errors.Is(err, ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx but received %v", testName, err)
+	}
+	_ = tx.Rollback()
+
+	// SELECT statements are executed with Query outside transactions
+	if _, err = db.Exec(`SELECT * FROM "accounts" WHERE id=?`, "a"); err == nil {
+		t.Fatalf("%s failed: expected error for Exec of SELECT outside transactions", testName)
+	}
+}
+
+func TestTx_Returning(t *testing.T) {
+	testName := "TestTx_Returning"
+	var statements []interface{}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		statements = req["TransactStatements"].([]interface{})
+		responses := make([]interface{}, len(statements))
+		for i, statement := range statements {
+			responses[i] = map[string]interface{}{}
+			if strings.HasSuffix(statement.(map[string]interface{})["Statement"].(string), "RETURNING ALL OLD *") {
+				responses[i] = map[string]interface{}{"Item": map[string]interface{}{
+					"id":      map[string]interface{}{"S": "a"},
+					"balance": map[string]interface{}{"N": "100"},
+				}}
+			}
+		}
+		return stubResponse{body: map[string]interface{}{"Responses": responses}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	ctx, results := WithTxResultCollector(context.Background())
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE "accounts" SET balance=? WHERE id=? RETURNING ALL OLD *`, 50, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	result1, err := tx.ExecContext(ctx, `DELETE FROM "accounts" WHERE id=? RETURNING ALL OLD *`, "a")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	result2, err := tx.ExecContext(ctx, `UPDATE "accounts" SET balance=? WHERE id=?`, 0, "b")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.QueryContext(ctx, `UPDATE "accounts" SET balance=? WHERE id=? RETURNING ALL OLD *`, 50, "a"); !errors.Is(err, ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx but received %v", testName, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(statements) != 3 {
+		t.Fatalf("%s failed: expected 3 statements in transaction but received %d", testName, len(statements))
+	}
+	if statement := statements[2].(map[string]interface{})["Statement"]; statement != `UPDATE "accounts" SET balance=? WHERE id=?` {
+		t.Fatalf("%s failed: unexpected statement %s", testName, statement)
+	}
+
+	expected := []map[string]interface{}{{"id": "a", "balance": 100.0}}
+	if items, err := results.Items(0); err != nil || !reflect.DeepEqual(items, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, expected, items, err)
+	}
+	if n, err := result1.RowsAffected(); err != nil || n != 1 {
+		t.Fatalf("%s failed: expected 1 affected row but received %d (error %v)", testName, n, err)
+	}
+	if n, err := result2.RowsAffected(); err != nil || n != 1 {
+		t.Fatalf("%s failed: expected 1 affected row but received %d (error %v)", testName, n, err)
+	}
+}
+
+func TestTx_Returning_emptyResponses(t *testing.T) {
+	testName := "TestTx_Returning_emptyResponses"
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		return stubResponse{body: map[string]interface{}{"Responses": []interface{}{}}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	result1, _ := tx.Exec(`UPDATE "accounts" SET balance=? WHERE id=? RETURNING ALL OLD *`, 50, "a")
+	result2, _ := tx.Exec(`DELETE FROM "accounts" WHERE id=? RETURNING ALL OLD *`, "b")
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	for i, result := range []sql.Result{result1, result2} {
+		if _, err := result.RowsAffected(); !errors.Is(err, ErrRowsAffectedUnavailable) {
+			t.Fatalf("%s failed: expected ErrRowsAffectedUnavailable for statement %d but received %v", testName, i, err)
+		}
+	}
+}
+
+func TestTx_ResultCollector(t *testing.T) {
+	testName := "TestTx_ResultCollector"
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		statements := req["TransactStatements"].([]interface{})
+		responses := make([]interface{}, len(statements))
+		for i, statement := range statements {
+			responses[i] = map[string]interface{}{}
+			query := statement.(map[string]interface{})["Statement"].(string)
+			params := statement.(map[string]interface{})["Parameters"].([]interface{})
+			id := params[len(params)-1].(map[string]interface{})["S"].(string)
+			if (strings.HasPrefix(query, "SELECT") || strings.HasSuffix(query, "RETURNING ALL OLD *")) && id != "missing" {
+				responses[i] = map[string]interface{}{"Item": map[string]interface{}{
+					"id":      map[string]interface{}{"S": id},
+					"balance": map[string]interface{}{"N": "100"},
+				}}
+			}
+		}
+		return stubResponse{body: map[string]interface{}{"Responses": responses}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	// read-only transaction
+	ctx, results := WithTxResultCollector(context.Background())
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	for _, id := range []string{"a", "missing"} {
+		if _, err = tx.ExecContext(ctx, `SELECT * FROM "accounts" WHERE id=?`, id); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+	}
+	if _, err = results.Items(0); !errors.Is(err, ErrInTx) {
+		t.Fatalf("%s failed: expected ErrInTx but received %v", testName, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n := results.NumStatements(); n != 2 {
+		t.Fatalf("%s failed: expected 2 statements but received %d", testName, n)
+	}
+	expected := []map[string]interface{}{{"id": "a", "balance": 100.0}}
+	if items, err := results.Items(0); err != nil || !reflect.DeepEqual(items, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, expected, items, err)
+	}
+	if items, err := results.Items(1); err != nil || len(items) != 0 {
+		t.Fatalf("%s failed: expected no item but received %#v (error %v)", testName, items, err)
+	}
+	if _, err = results.Items(2); err == nil {
+		t.Fatalf("%s failed: expected error for a statement not collected", testName)
+	}
+
+	// RETURNING clause in read-write transaction
+	ctx, results = WithTxResultCollector(context.Background())
+	tx, _ = db.BeginTx(ctx, nil)
+	if _, err = tx.ExecContext(ctx, `UPDATE "accounts" SET balance=? WHERE id=? RETURNING ALL OLD *`, 50, "b"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE "accounts" SET balance=? WHERE id=?`, 50, "c"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.Exec(`UPDATE "accounts" SET balance=? WHERE id=?`, 50, "d"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if n := results.NumStatements(); n != 2 {
+		t.Fatalf("%s failed: expected statements executed with the context only but received %d", testName, n)
+	}
+	expected = []map[string]interface{}{{"id": "b", "balance": 100.0}}
+	if items, err := results.Items(0); err != nil || !reflect.DeepEqual(items, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v (error %v)", testName, expected, items, err)
+	}
+	if items, err := results.Items(1); err != nil || len(items) != 0 {
+		t.Fatalf("%s failed: expected no item but received %#v (error %v)", testName, items, err)
+	}
+}
+
+func TestTx_Token(t *testing.T) {
+	testName := "TestTx_Token"
+	var lock sync.Mutex
+	var tokens []string
+	sentTokens := func(reset bool) []string {
+		lock.Lock()
+		defer lock.Unlock()
+		result := tokens
+		if reset {
+			tokens = nil
+		}
+		return result
+	}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		lock.Lock()
+		tokens = append(tokens, req["ClientRequestToken"].(string))
+		lock.Unlock()
+		statement := req["TransactStatements"].([]interface{})[0].(map[string]interface{})["Statement"].(string)
+		switch {
+		case strings.Contains(statement, "slow"):
+			time.Sleep(300 * time.Millisecond)
+		case strings.Contains(statement, "internal"):
+			return stubError(500, "InternalServerError", "internal error")
+		case strings.Contains(statement, "canceled"):
+			return stubError(400, "TransactionCanceledException", "Transaction cancelled")
+		}
+		return stubResponse{body: map[string]interface{}{"Responses": []interface{}{map[string]interface{}{}}}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("TimeoutMs=100;MaxAttempts=2;MaxBackoffMs=10"))
+	defer func() { _ = db.Close() }()
+
+	commit := func(ctx context.Context, table string) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(`INSERT INTO "`+table+`" VALUE {'id': ?}`, "1"); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if err := commit(WithTxToken(context.Background(), "my-token"), "tbl"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err := commit(context.Background(), "tbl"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if sent := sentTokens(true); sent[0] != "my-token" || len(sent[1]) != 32 {
+		t.Fatalf("%s failed: unexpected tokens %#v", testName, sent)
+	}
+
+	var unknownErr *TxOutcomeUnknownError
+	err := commit(context.Background(), "slow")
+	if !errors.As(err, &unknownErr) || !errors.Is(err, context.DeadlineExceeded) || unknownErr.Token != sentTokens(true)[0] {
+		t.Fatalf("%s failed: expected TxOutcomeUnknownError but received %v", testName, err)
+	}
+
+	err = commit(context.Background(), "internal")
+	if !errors.As(err, &unknownErr) || !IsAwsError(err, "InternalServerError") {
+		t.Fatalf("%s failed: expected TxOutcomeUnknownError but received %v", testName, err)
+	}
+	if sent := sentTokens(true); len(sent) != 2 || sent[0] != sent[1] || unknownErr.Token != sent[0] {
+		t.Fatalf("%s failed: expected the token to be reused by retries but received %#v", testName, sent)
+	}
+
+	if err = commit(context.Background(), "canceled"); err == nil || errors.As(err, &unknownErr) {
+		t.Fatalf("%s failed: expected a known failure but received %v", testName, err)
+	}
+}
+
+func TestTx_Token_conflictRetry(t *testing.T) {
+	testName := "TestTx_Token_conflictRetry"
+	var lock sync.Mutex
+	var tokens []interface{}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		lock.Lock()
+		defer lock.Unlock()
+		tokens = append(tokens, req["ClientRequestToken"])
+		if len(tokens)%2 == 1 {
+			resp := stubError(400, "TransactionCanceledException", "Transaction cancelled, please refer cancellation reasons for specific reasons [TransactionConflict]")
+			resp.body.(map[string]interface{})["CancellationReasons"] = []interface{}{
+				map[string]interface{}{"Code": "TransactionConflict", "Message": "Transaction is ongoing for the item"},
+			}
+			return resp
+		}
+		return stubResponse{body: map[string]interface{}{"Responses": []interface{}{map[string]interface{}{}}}}
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("TxConflictMaxAttempts=3;TxConflictMaxBackoffMs=10"))
+	defer func() { _ = db.Close() }()
+
+	sentTokens := func() []interface{} {
+		lock.Lock()
+		defer lock.Unlock()
+		result := tokens
+		tokens = nil
+		return result
+	}
+	commit := func(ctx context.Context, opts *sql.TxOptions, query string) error {
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(query, "1"); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	testData := []struct {
+		name     string
+		ctx      context.Context
+		opts     *sql.TxOptions
+		query    string
+		validate func(sent []interface{}) bool
+	}{
+		{name: "generated_token", ctx: context.Background(), query: `INSERT INTO "tbl" VALUE {'id': ?}`,
+			validate: func(sent []interface{}) bool {
+				first, _ := sent[0].(string)
+				second, _ := sent[1].(string)
+				return len(first) == 32 && len(second) == 32 && first != second
+			}},
+		{name: "supplied_token", ctx: WithTxToken(context.Background(), "my-token"), query: `INSERT INTO "tbl" VALUE {'id': ?}`,
+			validate: func(sent []interface{}) bool { return sent[0] == "my-token" && sent[1] == "my-token" }},
+		{name: "read_only", ctx: WithTxToken(context.Background(), "my-token"), opts: &sql.TxOptions{ReadOnly: true}, query: `SELECT * FROM "tbl" WHERE id=?`,
+			validate: func(sent []interface{}) bool { return sent[0] == nil && sent[1] == nil }},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := commit(testCase.ctx, testCase.opts, testCase.query); err != nil {
+				t.Fatalf("%s failed: %s", testName+"/"+testCase.name, err)
+			}
+			if sent := sentTokens(); len(sent) != 2 || !testCase.validate(sent) {
+				t.Fatalf("%s failed: unexpected tokens %#v", testName+"/"+testCase.name, sent)
+			}
+		})
+	}
+}
+
+func TestTx_Canceled(t *testing.T) {
+	testName := "TestTx_Canceled"
+	var request map[string]interface{}
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		request = req
+		resp := stubError(400, "TransactionCanceledException", "Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]")
+		resp.body.(map[string]interface{})["CancellationReasons"] = []interface{}{
+			map[string]interface{}{"Code": "None"},
+			map[string]interface{}{"Code": "ConditionalCheckFailed", "Message": "The conditional request failed",
+				"Item": map[string]interface{}{"id": map[string]interface{}{"S": "b"}, "balance": map[string]interface{}{"N": "5"}}},
+		}
+		return resp
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	result1, _ := tx.Exec(`UPDATE "accounts" SET balance=balance+? WHERE id=?`, 10, "a")
+	result2, _ := tx.Exec(`UPDATE "accounts" SET balance=balance-? WHERE id=? AND balance>=?`, 10, "b", 10)
+	err = tx.Commit()
+
+	var txErr *TxCanceledError
+	if !errors.As(err, &txErr) || !IsAwsError(err, "TransactionCanceledException") {
+		t.Fatalf("%s failed: expected TxCanceledError but received %v", testName, err)
+	}
+	if len(txErr.Statements) != 2 {
+		t.Fatalf("%s failed: expected 2 statements but received %d", testName, len(txErr.Statements))
+	}
+	expected := &TxStatementError{Index: 1, Query: `UPDATE "accounts" SET balance=balance-? WHERE id=? AND balance>=?`,
+		Code: "ConditionalCheckFailed", Message: "The conditional request failed",
+		Item: map[string]interface{}{"id": "b", "balance": 5.0}, tx: txErr}
+	if stmtErr := txErr.Statements[1]; !reflect.DeepEqual(stmtErr, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, stmtErr)
+	}
+	if stmtErr := txErr.Statements[0]; stmtErr.Index != 0 || stmtErr.Code != "None" || stmtErr.Item != nil {
+		t.Fatalf("%s failed: unexpected reason of statement 0: %#v", testName, stmtErr)
+	}
+	if !strings.Contains(err.Error(), "statement 2-th") || !strings.Contains(err.Error(), "ConditionalCheckFailed") {
+		t.Fatalf("%s failed: unexpected error message %s", testName, err)
+	}
+
+	stmts := request["TransactStatements"].([]interface{})
+	for i, stmt := range stmts {
+		if v := stmt.(map[string]interface{})["ReturnValuesOnConditionCheckFailure"]; v != "ALL_OLD" {
+			t.Fatalf("%s failed: expected ALL_OLD for statement %d but received %#v", testName, i, v)
+		}
+	}
+
+	for i, result := range []sql.Result{result1, result2} {
+		var stmtErr *TxStatementError
+		if _, err = result.RowsAffected(); !errors.As(err, &stmtErr) || stmtErr != txErr.Statements[i] || !errors.As(err, new(*TxCanceledError)) {
+			t.Fatalf("%s failed: expected TxStatementError for statement %d but received %v", testName, i, err)
+		}
+	}
+}
+
+func TestTx_Canceled_upsert(t *testing.T) {
+	testName := "TestTx_Canceled_upsert"
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		if op != "TransactWriteItems" {
+			return stubError(400, "ValidationException", "unexpected operation "+op)
+		}
+		resp := stubError(400, "TransactionCanceledException", "Transaction cancelled, please refer cancellation reasons for specific reasons [None, TransactionConflict]")
+		resp.body.(map[string]interface{})["CancellationReasons"] = []interface{}{
+			map[string]interface{}{"Code": "None"},
+			map[string]interface{}{"Code": "TransactionConflict", "Message": "Transaction is ongoing for the item"},
+		}
+		return resp
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr("TxConflictMaxAttempts=1"))
+	defer func() { _ = db.Close() }()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	result1, _ := tx.Exec(`UPSERT INTO "accounts" VALUE {'id': ?}`, "a")
+	result2, _ := tx.Exec(`REPLACE INTO "accounts" VALUE {'id': ?}`, "b")
+	var txErr *TxCanceledError
+	if err = tx.Commit(); !errors.As(err, &txErr) {
+		t.Fatalf("%s failed: expected TxCanceledError but received %v", testName, err)
+	}
+	for i, result := range []sql.Result{result1, result2} {
+		var stmtErr *TxStatementError
+		if _, err = result.RowsAffected(); !errors.As(err, &stmtErr) || stmtErr != txErr.Statements[i] {
+			t.Fatalf("%s failed: expected TxStatementError for statement %d but received %v", testName, i, err)
+		}
+	}
+	if code := txErr.Statements[1].Code; code != "TransactionConflict" {
+		t.Fatalf("%s failed: expected TransactionConflict but received %s", testName, code)
+	}
+}
+
+func TestTx_PreparedStmt(t *testing.T) {
+	testName := "TestTx_PreparedStmt"
+	var lock sync.Mutex
+	var statements []string
+	server := newStubDynamoDBServer(func(op string, req map[string]interface{}) stubResponse {
+		lock.Lock()
+		defer lock.Unlock()
+		switch op {
+		case "ExecuteStatement":
+			statements = append(statements, req["Statement"].(string))
+			return stubResponse{body: map[string]interface{}{"Items": []interface{}{map[string]interface{}{"id": map[string]interface{}{"S": "a"}}}}}
+		case "ExecuteTransaction":
+			for _, stmt := range req["TransactStatements"].([]interface{}) {
+				statements = append(statements, stmt.(map[string]interface{})["Statement"].(string))
+			}
+			return stubResponse{body: map[string]interface{}{"Responses": []interface{}{map[string]interface{}{}, map[string]interface{}{}}}}
+		}
+		return stubError(400, "ValidationException", "unexpected operation "+op)
+	})
+	defer server.Close()
+	db, _ := sql.Open("godynamo", server.connStr(""))
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// prepared outside the transaction
+	stmtUpdate, err := conn.PrepareContext(ctx, `UPDATE "accounts" SET balance=? WHERE id=?`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	// prepared inside the transaction
+	stmtDelete, err := conn.PrepareContext(ctx, `DELETE FROM "accounts" WHERE id=?`)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.StmtContext(ctx, stmtUpdate).ExecContext(ctx, 10, "a"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err = tx.StmtContext(ctx, stmtDelete).ExecContext(ctx, "b"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	for stmt, args := range map[*sql.Stmt][]interface{}{stmtUpdate: {20, "a"}, stmtDelete: {"a"}} {
+		result, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n != 1 {
+			t.Fatalf("%s failed: expected 1 affected row but received %d (error %v)", testName, n, err)
+		}
+	}
+
+	expected := []string{
+		`UPDATE "accounts" SET balance=? WHERE id=?`,
+		`DELETE FROM "accounts" WHERE id=?`,
+		`DELETE FROM "accounts" WHERE id=? RETURNING ALL OLD *`,
+		`UPDATE "accounts" SET balance=? WHERE id=? RETURNING ALL OLD *`,
+	}
+	sort.Strings(statements[2:])
+	if !reflect.DeepEqual(statements, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, statements)
+	}
+}
@@ -0,0 +1,119 @@
+package godynamo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TxCanceledError is returned when committing a transaction which DynamoDB canceled (TransactionCanceledException),
+// e.g. because the condition check of one of its statements failed. It reports the cancellation reason of each
+// statement, in the order the statements were added to the transaction.
+//
+// Example:
+//
+//	err := tx.Commit()
+//	var txErr *godynamo.TxCanceledError
+//	if errors.As(err, &txErr) {
+//		for _, stmtErr := range txErr.Statements {
+//			if stmtErr.Code != "None" {
+//				fmt.Println(stmtErr.Index, stmtErr.Query, stmtErr.Code, stmtErr.Message)
+//			}
+//		}
+//	}
+//
+// @Available since v1.4.0
+type TxCanceledError struct {
+	// Statements holds the cancellation reason of each statement of the transaction.
+	Statements []*TxStatementError
+
+	// Err is the error returned by DynamoDB.
+	Err error
+}
+
+// Error implements error/Error.
+func (e *TxCanceledError) Error() string {
+	for _, stmtErr := range e.Statements {
+		if stmtErr.Code != "None" {
+			return fmt.Sprintf("transaction canceled: %s", stmtErr.reason())
+		}
+	}
+	return fmt.Sprintf("transaction canceled: %s", e.Err)
+}
+
+// Unwrap returns the error returned by DynamoDB, so that IsAwsError still applies.
+func (e *TxCanceledError) Unwrap() error {
+	return e.Err
+}
+
+// TxStatementError is the cancellation reason of a statement of a canceled transaction. It is also returned by the
+// results of the statement, e.g. by RowsAffected, and unwraps to the *TxCanceledError of the transaction.
+//
+// @Available since v1.4.0
+type TxStatementError struct {
+	// Index is the position of the statement in the transaction, starting from 0.
+	Index int
+
+	// Query is the statement as it was executed, e.g. `UPDATE "tbl" SET n=? WHERE id=?`.
+	Query string
+
+	// Code is the cancellation reason code, e.g. "None" (the statement did not cause the cancellation),
+	// "ConditionalCheckFailed", "TransactionConflict" or "ValidationError".
+	Code string
+
+	// Message is the cancellation reason message, if any.
+	Message string
+
+	// Item is the current item of a statement whose condition check failed, if it exists. Numbers are returned as
+	// configured by the statement's number mode.
+	Item map[string]interface{}
+
+	tx *TxCanceledError
+}
+
+func (e *TxStatementError) reason() string {
+	result := fmt.Sprintf("statement %d-th <%s>: %s", e.Index+1, e.Query, e.Code)
+	if e.Message != "" {
+		result += " (" + e.Message + ")"
+	}
+	return result
+}
+
+// Error implements error/Error.
+func (e *TxStatementError) Error() string {
+	return fmt.Sprintf("transaction canceled: %s", e.reason())
+}
+
+// Unwrap returns the *TxCanceledError of the transaction.
+func (e *TxStatementError) Unwrap() error {
+	return e.tx
+}
+
+// asTxCanceledError maps the cancellation reasons of err to txStmtList, the statements of the transaction. It returns
+// nil if err is not a TransactionCanceledException.
+func asTxCanceledError(err error, txStmtList []*txStmt) *TxCanceledError {
+	var canceledErr *types.TransactionCanceledException
+	if !errors.As(err, &canceledErr) {
+		return nil
+	}
+	result := &TxCanceledError{Statements: make([]*TxStatementError, len(txStmtList)), Err: err}
+	for i, txStmt := range txStmtList {
+		stmtErr := &TxStatementError{Index: i, Query: txStmt.stmt.query, Code: "None", tx: result}
+		if i < len(canceledErr.CancellationReasons) {
+			reason := canceledErr.CancellationReasons[i]
+			if code := aws.ToString(reason.Code); code != "" {
+				stmtErr.Code = code
+			}
+			stmtErr.Message = aws.ToString(reason.Message)
+			if len(reason.Item) > 0 {
+				if item, uerr := txStmt.stmt.numberMode().unmarshal(&types.AttributeValueMemberM{Value: reason.Item}); uerr == nil {
+					stmtErr.Item, _ = item.(map[string]interface{})
+				}
+			}
+		}
+		result.Statements[i] = stmtErr
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+package godynamo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type txResultCollectorKey struct{}
+
+// TxResultCollector collects the statements executed in transactions with a context, so that the items they return
+// (SELECT statements of read-only transactions, UPDATE/DELETE statements with RETURNING clause) can be read once the
+// transaction is committed.
+//
+// It is the only way to read these items: statements are executed in transactions with Exec, since database/sql
+// closes the rows of tx.Query when committing, before the results are available. Query returns ErrInTx in
+// transactions.
+//
+// @Available since v1.4.0
+type TxResultCollector struct {
+	lock       sync.Mutex
+	statements []*txStmt
+}
+
+// WithTxResultCollector returns a copy of ctx with a new TxResultCollector attached. Statements executed in
+// transactions with the returned context (via tx.ExecContext) are added to the collector, in the order they are
+// executed.
+//
+// Example:
+//
+//	ctx, results := godynamo.WithTxResultCollector(context.Background())
+//	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+//	...
+//	_, err = tx.ExecContext(ctx, `SELECT balance FROM "accounts" WHERE id=?`, "acc1")
+//	_, err = tx.ExecContext(ctx, `SELECT balance FROM "accounts" WHERE id=?`, "acc2")
+//	err = tx.Commit()
+//	items1, err := results.Items(0) // items returned by the 1st statement
+//	items2, err := results.Items(1) // items returned by the 2nd statement
+//
+// @Available since v1.4.0
+func WithTxResultCollector(ctx context.Context) (context.Context, *TxResultCollector) {
+	collector := &TxResultCollector{}
+	return context.WithValue(ctx, txResultCollectorKey{}, collector), collector
+}
+
+// txResultCollectorFromContext returns the TxResultCollector attached to ctx, or nil if none.
+func txResultCollectorFromContext(ctx context.Context) *TxResultCollector {
+	if ctx == nil {
+		return nil
+	}
+	collector, _ := ctx.Value(txResultCollectorKey{}).(*TxResultCollector)
+	return collector
+}
+
+// add adds a statement of a transaction to the collector. It is safe to call add on a nil collector.
+func (c *TxResultCollector) add(txStmt *txStmt) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.statements = append(c.statements, txStmt)
+}
+
+// NumStatements returns the number of statements added to the collector.
+func (c *TxResultCollector) NumStatements() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.statements)
+}
+
+// Items returns the items returned by the index-th statement added to the collector (starting from 0), with numbers
+// returned as configured by the statement's number mode. A statement returning no item (e.g. a SELECT statement whose
+// item does not exist, or an UPDATE statement without RETURNING clause) has no items.
+//
+// ErrInTx is returned if the transaction has not been committed (or was rolled back). If committing the transaction
+// failed, the error is returned, e.g. the *TxStatementError of the statement if the transaction was canceled.
+func (c *TxResultCollector) Items(index int) ([]map[string]interface{}, error) {
+	c.lock.Lock()
+	if index < 0 || index >= len(c.statements) {
+		c.lock.Unlock()
+		return nil, fmt.Errorf("statement %d-th not found, %d statements collected", index+1, len(c.statements))
+	}
+	txStmt := c.statements[index]
+	c.lock.Unlock()
+
+	if txStmt.err != nil {
+		return nil, txStmt.err
+	}
+	if txStmt.output == nil {
+		return nil, ErrInTx
+	}
+	items := make([]map[string]interface{}, 0, len(txStmt.output.Items))
+	for _, item := range txStmt.output.Items {
+		value, err := txStmt.stmt.numberMode().unmarshal(&types.AttributeValueMemberM{Value: item})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value.(map[string]interface{}))
+	}
+	return items, nil
+}
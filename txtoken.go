@@ -0,0 +1,99 @@
+package godynamo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+)
+
+type txTokenKey struct{}
+
+// WithTxToken returns a copy of ctx carrying an idempotency token for transactions started with it (e.g. via
+// db.BeginTx). The token is sent as the ClientRequestToken when committing, so that committing again a transaction
+// with the same token and the same statements (within 10 minutes) does not execute it twice. The token must be 1 to
+// 36 characters long.
+//
+// If no token is supplied, one is generated when the transaction starts; it is reused by the retries of the AWS SDK
+// and is reported by TxOutcomeUnknownError. A transaction canceled due to conflicts and retried per
+// Config.TxConflictRetry is committed again with a newly generated token, while a supplied token is reused as is.
+//
+// Tokens are only sent for transactions with write statements: read-only transactions are committed without token,
+// so that DynamoDB never returns the items read by an earlier commit.
+//
+// Example:
+//
+//	err := commitTransfer(ctx)
+//	var unknownErr *godynamo.TxOutcomeUnknownError
+//	if errors.As(err, &unknownErr) {
+//		// the transaction may or may not have been committed, retry it safely with the same token
+//		err = commitTransfer(godynamo.WithTxToken(ctx, unknownErr.Token))
+//	}
+//
+// @Available since v1.4.0
+func WithTxToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, txTokenKey{}, token)
+}
+
+// txTokenFromContext returns the idempotency token attached to ctx and true, or a newly generated one and false.
+func txTokenFromContext(ctx context.Context) (string, bool) {
+	if ctx != nil {
+		if token, ok := ctx.Value(txTokenKey{}).(string); ok && token != "" {
+			return token, true
+		}
+	}
+	return newTxToken(), false
+}
+
+// newTxToken generates a random idempotency token.
+func newTxToken() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// TxOutcomeUnknownError is returned when committing a transaction fails without knowing whether DynamoDB executed
+// it, e.g. on timeouts, network errors or internal server errors. The transaction can be retried safely with the same
+// statements and Token, see WithTxToken. It is not returned for read-only transactions, which can simply be retried.
+//
+// @Available since v1.4.0
+type TxOutcomeUnknownError struct {
+	// Token is the idempotency token (ClientRequestToken) the transaction was committed with.
+	Token string
+
+	// Err is the error committing the transaction.
+	Err error
+}
+
+// Error implements error/Error.
+func (e *TxOutcomeUnknownError) Error() string {
+	return fmt.Sprintf("outcome of transaction (token %s) is unknown: %s", e.Token, e.Err)
+}
+
+// Unwrap returns the error committing the transaction.
+func (e *TxOutcomeUnknownError) Unwrap() error {
+	return e.Err
+}
+
+// txCommitError wraps err, the error committing a transaction with token, into a *TxOutcomeUnknownError if DynamoDB
+// may have executed the transaction.
+func txCommitError(token string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var paramsErr smithy.InvalidParamsError
+	if errors.As(err, &paramsErr) {
+		// the request was not sent
+		return err
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() > 0 && respErr.HTTPStatusCode() < 500 {
+		// DynamoDB rejected the transaction (status code 0 means no response was received)
+		return err
+	}
+	return &TxOutcomeUnknownError{Token: token, Err: err}
+}